@@ -0,0 +1,208 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// time.Location 의 달력 시간 (매일 04:00 등) 으로 humantimetask 의 다음 실행 시간을 계산
+// DST 로 건너뛰거나 두번 나오는 시간의 처리를 정할수 있다.
+package calendarschedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasworld/timedtask/humantimetask"
+)
+
+var _ humantimetask.Schedule = &Schedule{}
+
+// 이 기간 안에 맞는 날이 없으면 없는것으로 본다.
+const searchDays = 400
+
+// DST 시작으로 없는 시간 (02:30 이 없는 날 등)
+type GapPolicy int
+
+const (
+	// 건너뛴 시간이 끝나는 시간 (03:00) 에 실행
+	GapRunAtTransition GapPolicy = iota
+	// 건너뛴 길이 만큼 뒤 (03:30) 에 실행
+	GapShift
+	// 그날은 실행 안함
+	GapSkip
+)
+
+// DST 끝으로 두번 나오는 시간 (01:30 이 두번인 날 등)
+type OverlapPolicy int
+
+const (
+	// 처음 시간에 한번 실행
+	OverlapFirst OverlapPolicy = iota
+	// 두번째 시간에 한번 실행
+	OverlapSecond
+	// 두번 다 실행
+	OverlapBoth
+)
+
+type Schedule struct {
+	loc      *time.Location
+	hour     int
+	minute   int
+	second   int
+	weekdays uint8 // 0 : every day
+	monthDay int   // 0 : every day, 달의 마지막 날 보다 크면 마지막 날
+
+	Gap     GapPolicy
+	Overlap OverlapPolicy
+}
+
+func Daily(loc *time.Location, hour, minute, second int) *Schedule {
+	return &Schedule{
+		loc:    loc,
+		hour:   hour,
+		minute: minute,
+		second: second,
+	}
+}
+
+func Weekly(loc *time.Location, hour, minute, second int, weekdays ...time.Weekday) *Schedule {
+	cs := Daily(loc, hour, minute, second)
+	for _, wd := range weekdays {
+		cs.weekdays |= 1 << uint(wd)
+	}
+	return cs
+}
+
+func Monthly(loc *time.Location, day int, hour, minute, second int) *Schedule {
+	cs := Daily(loc, hour, minute, second)
+	cs.monthDay = day
+	return cs
+}
+
+func (cs Schedule) String() string {
+	return fmt.Sprintf("Calendar[%02d:%02d:%02d %v]",
+		cs.hour, cs.minute, cs.second, cs.loc)
+}
+
+func (cs *Schedule) Location() *time.Location {
+	return cs.loc
+}
+
+// prev 이후 (prev 는 제외) 의 첫 실행 시간, 없으면 zero time
+// 결과는 schedule 의 location 시간 이다.
+func (cs *Schedule) Next(prev time.Time) time.Time {
+	p := prev.In(cs.loc)
+	// 전날 부터 보는 것은 전날 의 두번째 overlap 시간이 prev 이후 일수 있어서
+	y, m, d := p.AddDate(0, 0, -1).Date()
+	for i := 0; i < searchDays; i++ {
+		day := time.Date(y, m, d+i, 12, 0, 0, 0, cs.loc)
+		if !cs.dayMatch(day) {
+			continue
+		}
+		for _, t := range cs.occurrences(day.Year(), day.Month(), day.Day()) {
+			if t.After(prev) {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (cs *Schedule) dayMatch(day time.Time) bool {
+	if cs.weekdays != 0 && cs.weekdays&(1<<uint(day.Weekday())) == 0 {
+		return false
+	}
+	if cs.monthDay > 0 {
+		lastDay := time.Date(day.Year(), day.Month()+1, 0, 12, 0, 0, 0, cs.loc).Day()
+		want := cs.monthDay
+		if want > lastDay {
+			want = lastDay
+		}
+		return day.Day() == want
+	}
+	return true
+}
+
+// 그날 실행할 시간들, policy 를 적용한 결과
+func (cs *Schedule) occurrences(y int, m time.Month, d int) []time.Time {
+	occ, gapEnd := resolveWall(y, m, d, cs.hour, cs.minute, cs.second, cs.loc)
+	switch len(occ) {
+	case 0:
+		switch cs.Gap {
+		case GapSkip:
+			return nil
+		case GapShift:
+			// 건너뛴 시간 직전의 offset 으로 계산한 시간
+			_, offBefore := gapEnd.Add(-time.Second).Zone()
+			wall := time.Date(y, m, d, cs.hour, cs.minute, cs.second, 0, time.UTC)
+			return []time.Time{wall.Add(-time.Duration(offBefore) * time.Second).In(cs.loc)}
+		default:
+			return []time.Time{gapEnd}
+		}
+	case 1:
+		return occ
+	default:
+		switch cs.Overlap {
+		case OverlapBoth:
+			return occ
+		case OverlapSecond:
+			return occ[len(occ)-1:]
+		default:
+			return occ[:1]
+		}
+	}
+}
+
+// wall clock 시간에 해당하는 실제 시간들 (빠른 순)
+// 없으면 (DST gap) 건너뛴 시간이 끝나는 시간을 준다.
+func resolveWall(y int, m time.Month, d, hour, minute, second int, loc *time.Location) ([]time.Time, time.Time) {
+	wall := time.Date(y, m, d, hour, minute, second, 0, time.UTC)
+
+	// 전후 하루 안의 offset 들로 가능한 시간을 만들어 본다.
+	_, offBefore := wall.Add(-36 * time.Hour).In(loc).Zone()
+	_, offAfter := wall.Add(36 * time.Hour).In(loc).Zone()
+	var occ []time.Time
+	for _, off := range []int{offBefore, offAfter} {
+		t := wall.Add(-time.Duration(off) * time.Second).In(loc)
+		if !sameWall(t, y, m, d, hour, minute, second) {
+			continue
+		}
+		if len(occ) > 0 && occ[0].Equal(t) {
+			continue
+		}
+		occ = append(occ, t)
+	}
+	if len(occ) == 2 && occ[1].Before(occ[0]) {
+		occ[0], occ[1] = occ[1], occ[0]
+	}
+	if len(occ) > 0 {
+		return occ, time.Time{}
+	}
+
+	// gap : offset 이 바뀌는 시간을 찾는다.
+	lo := wall.Add(-time.Duration(offBefore) * time.Second)
+	hi := wall.Add(-time.Duration(offAfter) * time.Second)
+	if hi.Before(lo) {
+		lo, hi = hi, lo
+	}
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2).Truncate(time.Second)
+		if _, off := mid.In(loc).Zone(); off == offBefore {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return nil, hi.In(loc)
+}
+
+func sameWall(t time.Time, y int, m time.Month, d, hour, minute, second int) bool {
+	ty, tm, td := t.Date()
+	th, tmin, ts := t.Clock()
+	return ty == y && tm == m && td == d && th == hour && tmin == minute && ts == second
+}
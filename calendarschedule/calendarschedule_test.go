@@ -0,0 +1,87 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calendarschedule
+
+import (
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no tzdata %v", err)
+	}
+	return loc
+}
+
+func TestSchedule_Next(t *testing.T) {
+	seoul := loadLocation(t, "Asia/Seoul")
+	cs := Daily(seoul, 4, 0, 0)
+	prev := time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC) // 05-03 03:00 KST
+	want := time.Date(2019, 5, 3, 4, 0, 0, 0, seoul)
+	got := cs.Next(prev)
+	if !got.Equal(want) {
+		t.Errorf("Next %v, want %v", got, want)
+	}
+	if got = cs.Next(got); !got.Equal(want.AddDate(0, 0, 1)) {
+		t.Errorf("Next %v, want %v", got, want.AddDate(0, 0, 1))
+	}
+
+	cs = Weekly(seoul, 5, 0, 0, time.Monday)
+	if got := cs.Next(prev); !got.Equal(time.Date(2019, 5, 6, 5, 0, 0, 0, seoul)) {
+		t.Errorf("Weekly Next %v", got)
+	}
+	cs = Monthly(seoul, 31, 0, 0, 0)
+	prev = time.Date(2019, 6, 1, 0, 0, 0, 0, seoul)
+	if got := cs.Next(prev); !got.Equal(time.Date(2019, 6, 30, 0, 0, 0, 0, seoul)) {
+		t.Errorf("Monthly Next %v", got)
+	}
+}
+
+func TestSchedule_DST(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	// 2019-03-10 02:00 -> 03:00, 2019-11-03 02:00 -> 01:00
+	springPrev := time.Date(2019, 3, 9, 12, 0, 0, 0, ny)
+	fallPrev := time.Date(2019, 11, 2, 12, 0, 0, 0, ny)
+
+	cs := Daily(ny, 2, 30, 0)
+	if got := cs.Next(springPrev); !got.Equal(time.Date(2019, 3, 10, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("GapRunAtTransition %v", got)
+	}
+	cs.Gap = GapShift
+	if got := cs.Next(springPrev); !got.Equal(time.Date(2019, 3, 10, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("GapShift %v", got)
+	}
+	cs.Gap = GapSkip
+	if got := cs.Next(springPrev); !got.Equal(time.Date(2019, 3, 11, 2, 30, 0, 0, ny)) {
+		t.Errorf("GapSkip %v", got)
+	}
+
+	cs = Daily(ny, 1, 30, 0)
+	first := time.Date(2019, 11, 3, 5, 30, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	next := time.Date(2019, 11, 4, 1, 30, 0, 0, ny)
+	if got := cs.Next(fallPrev); !got.Equal(first) {
+		t.Errorf("OverlapFirst %v", got)
+	} else if got = cs.Next(got); !got.Equal(next) {
+		t.Errorf("OverlapFirst run twice %v", got)
+	}
+	cs.Overlap = OverlapSecond
+	if got := cs.Next(fallPrev); !got.Equal(second) {
+		t.Errorf("OverlapSecond %v", got)
+	}
+	cs.Overlap = OverlapBoth
+	if got := cs.Next(first); !got.Equal(second) {
+		t.Errorf("OverlapBoth %v", got)
+	}
+}
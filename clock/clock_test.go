@@ -0,0 +1,144 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	tm := f.NewTimer(time.Hour)
+	tk := f.NewTicker(40 * time.Minute)
+	after := f.After(10 * time.Minute)
+	if f.Timers() != 3 {
+		t.Errorf("timers %v", f.Timers())
+	}
+
+	f.Advance(30 * time.Minute)
+	if got := <-after; !got.Equal(start.Add(10 * time.Minute)) {
+		t.Errorf("after fired at %v", got)
+	}
+	select {
+	case <-tm.C():
+		t.Errorf("timer fired early")
+	default:
+	}
+
+	f.Advance(30 * time.Minute)
+	if got := <-tk.C(); !got.Equal(start.Add(40 * time.Minute)) {
+		t.Errorf("ticker fired at %v", got)
+	}
+	if got := <-tm.C(); !got.Equal(start.Add(time.Hour)) || !f.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("timer fired at %v, now %v", got, f.Now())
+	}
+
+	if tm.Reset(time.Minute) {
+		t.Errorf("reset of fired timer returned active")
+	}
+	if !tm.Stop() || f.Timers() != 1 {
+		t.Errorf("stop failed, timers %v", f.Timers())
+	}
+	tk.Stop()
+	if _, ok := f.AdvanceToNext(); ok {
+		t.Errorf("AdvanceToNext with no timer")
+	}
+}
+
+func TestFake_AdvanceToNext(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	tm := f.NewTimer(time.Hour)
+	if now, ok := f.AdvanceToNext(); !ok || !now.Equal(start.Add(time.Hour)) {
+		t.Errorf("AdvanceToNext %v %v", now, ok)
+	}
+	<-tm.C()
+	tm.Reset(-time.Second)
+	<-tm.C()
+}
+
+func TestFake_AdvanceRearm(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	tm := f.NewTimer(time.Minute)
+	fired := make(chan time.Time, 10)
+	Ack(tm)
+	go func() {
+		for i := 0; i < 3; i++ {
+			fired <- <-tm.C()
+			tm.Reset(time.Minute)
+			Ack(tm)
+		}
+	}()
+	f.Advance(3 * time.Minute)
+	if len(fired) != 3 {
+		t.Fatalf("fired %v want 3", len(fired))
+	}
+	for i := 1; i <= 3; i++ {
+		if got := <-fired; !got.Equal(start.Add(time.Duration(i) * time.Minute)) {
+			t.Errorf("fire %v at %v", i, got)
+		}
+	}
+}
+
+func TestFake_AckTicker(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	tk := f.NewTicker(time.Second)
+	Ack(tk)
+	got := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for at := range tk.C() {
+			got++
+			if !at.Equal(start.Add(time.Duration(got) * time.Second)) {
+				t.Errorf("tick %v at %v", got, at)
+			}
+			if got == 7200 {
+				tk.Stop()
+				return
+			}
+			Ack(tk)
+		}
+	}()
+	f.Advance(2 * time.Hour)
+	<-done
+	if got != 7200 {
+		t.Errorf("ticks %v want 7200", got)
+	}
+}
+
+func TestWithTimeout_Fake(t *testing.T) {
+	f := NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := WithTimeout(context.Background(), f, time.Minute)
+	defer cancel()
+	f.Advance(30 * time.Second)
+	if ctx.Err() != nil {
+		t.Fatalf("canceled early %v", ctx.Err())
+	}
+	f.Advance(30 * time.Second)
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("err %v", ctx.Err())
+	}
+
+	ctx2, cancel2 := WithTimeout(context.Background(), f, time.Minute)
+	cancel2()
+	if !errors.Is(ctx2.Err(), context.Canceled) {
+		t.Errorf("err %v", ctx2.Err())
+	}
+}
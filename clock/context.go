@@ -0,0 +1,66 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// clk 의 시간으로 deadline 이 되면 취소 되는 ctx
+// Real 이면 context.WithDeadline 과 같다.
+func WithDeadline(parent context.Context, clk Clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	if _, ok := clk.(Real); ok {
+		return context.WithDeadline(parent, deadline)
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	timer := clk.NewTimer(deadline.Sub(clk.Now()))
+	Ack(timer) // Fake 의 Advance 는 cancel 하고 Stop 할 때 까지 기다린다.
+	go func() {
+		select {
+		case <-timer.C():
+			cancel(context.DeadlineExceeded)
+		case <-ctx.Done():
+		}
+		timer.Stop()
+	}()
+	return deadlineCtx{Context: ctx, deadline: deadline}, func() {
+		cancel(context.Canceled)
+	}
+}
+
+// clk 의 시간으로 timeout 이 지나면 취소 되는 ctx
+func WithTimeout(parent context.Context, clk Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return WithDeadline(parent, clk, clk.Now().Add(timeout))
+}
+
+// deadline 으로 취소 되면 Err 가 context.DeadlineExceeded 이다.
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c deadlineCtx) Deadline() (time.Time, bool) {
+	if d, ok := c.Context.Deadline(); ok && d.Before(c.deadline) {
+		return d, true
+	}
+	return c.deadline, true
+}
+
+func (c deadlineCtx) Err() error {
+	err := c.Context.Err()
+	if err != nil && errors.Is(context.Cause(c.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
@@ -0,0 +1,249 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Advance 를 불러야 시간이 간다.
+// timer, ticker 는 Advance 안에서 시간 순서대로, 같은 시간이면 만든 순서대로 보낸다.
+// 받지 않은 값이 있으면 time.Ticker 처럼 버린다.
+// clock.Ack 하는 timer 가 보낸 값은 Ack 할 때 까지 기다린 뒤에 다음 timer 를 보낸다.
+type Fake struct {
+	mutex   sync.Mutex
+	acked   *sync.Cond // Ack, Stop 할때 깨운다.
+	now     time.Time
+	timers  []*fakeTimer
+	unacked int // Ack 하는 timer 가 보내고 Ack 하지 않은 수
+}
+
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.acked = sync.NewCond(&f.mutex)
+	return f
+}
+
+type fakeTimer struct {
+	fake    *Fake
+	c       chan time.Time
+	when    time.Time
+	period  time.Duration // 0 : timer
+	active  bool
+	acking  bool // Ack 를 부른 적이 있고 Stop 하지 않았다.
+	unacked int
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// d <= 0 이면 바로 보낸다.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	ft := &fakeTimer{fake: f, c: make(chan time.Time, 1)}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.timers = append(f.timers, ft)
+	f.reset(ft, d)
+	return ft
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for clock.Fake.NewTicker")
+	}
+	ft := &fakeTimer{fake: f, c: make(chan time.Time, 1), period: d}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.timers = append(f.timers, ft)
+	f.reset(ft, d)
+	return fakeTicker{ft}
+}
+
+// 시간을 d 만큼 옮긴다. 그 사이에 시간이 된 timer 를 보낸다.
+// 받은 goroutine 이 Ack 전에 그 사이의 시간으로 timer 를 다시 맞추면 그것도 보낸다.
+// Ack 하는 goroutine 이 이 안에서 Advance 를 부르면 끝나지 않는다.
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	target := f.now.Add(d)
+	for {
+		f.waitAck()
+		ft := f.next()
+		if ft == nil || ft.when.After(target) {
+			if target.After(f.now) {
+				f.now = target
+			}
+			return
+		}
+		if ft.when.After(f.now) {
+			f.now = ft.when
+		}
+		f.fire(ft)
+	}
+}
+
+// 보낸 값을 모두 Ack 할 때 까지 기다린다. mutex 안에서 부른다.
+func (f *Fake) waitAck() {
+	for f.unacked > 0 {
+		f.acked.Wait()
+	}
+}
+
+// 시간을 t 로 옮긴다. 지나간 시간으로는 옮기지 않는다.
+func (f *Fake) Set(t time.Time) {
+	f.Advance(t.Sub(f.Now()))
+}
+
+// 다음 timer 의 시간 까지 옮겨서 보낸다. 기다리는 timer 가 없으면 false
+// Ack 하는 timer 면 Ack 할 때 까지 기다린다.
+func (f *Fake) AdvanceToNext() (time.Time, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.waitAck()
+	ft := f.next()
+	if ft == nil {
+		return f.now, false
+	}
+	if ft.when.After(f.now) {
+		f.now = ft.when
+	}
+	f.fire(ft)
+	f.waitAck()
+	return f.now, true
+}
+
+// 기다리는 timer, ticker 수
+func (f *Fake) Timers() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	n := 0
+	for _, ft := range f.timers {
+		if ft.active {
+			n++
+		}
+	}
+	return n
+}
+
+// 가장 먼저 시간이 되는 timer, mutex 안에서 부른다.
+func (f *Fake) next() *fakeTimer {
+	var rtn *fakeTimer
+	for _, ft := range f.timers {
+		if ft.active && (rtn == nil || ft.when.Before(rtn.when)) {
+			rtn = ft
+		}
+	}
+	return rtn
+}
+
+// mutex 안에서 부른다.
+func (f *Fake) fire(ft *fakeTimer) {
+	select {
+	case ft.c <- f.now:
+		if ft.acking {
+			ft.unacked++
+			f.unacked++
+		}
+	default:
+	}
+	if ft.period > 0 {
+		ft.when = ft.when.Add(ft.period)
+		return
+	}
+	f.remove(ft)
+}
+
+// mutex 안에서 부른다.
+func (f *Fake) reset(ft *fakeTimer, d time.Duration) {
+	ft.when = f.now.Add(d)
+	ft.active = true
+	if d <= 0 {
+		f.fire(ft)
+	}
+}
+
+// mutex 안에서 부른다.
+func (f *Fake) remove(ft *fakeTimer) {
+	ft.active = false
+	for i, v := range f.timers {
+		if v == ft {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (ft *fakeTimer) C() <-chan time.Time {
+	return ft.c
+}
+
+func (ft *fakeTimer) Stop() bool {
+	ft.fake.mutex.Lock()
+	defer ft.fake.mutex.Unlock()
+	wasActive := ft.active
+	ft.acking = false
+	ft.fake.unacked -= ft.unacked
+	ft.unacked = 0
+	ft.fake.remove(ft)
+	ft.fake.acked.Broadcast()
+	return wasActive
+}
+
+func (ft *fakeTimer) Reset(d time.Duration) bool {
+	ft.fake.mutex.Lock()
+	defer ft.fake.mutex.Unlock()
+	wasActive := ft.active
+	if !wasActive {
+		ft.fake.timers = append(ft.fake.timers, ft)
+	}
+	ft.fake.reset(ft, d)
+	return wasActive
+}
+
+// Fake 의 timer, ticker 면 받은 값을 처리 했다고 알린다. 다른 Clock 의 것은 아무것도 안한다.
+// 처음 부르면 이 timer 가 보낸 값은 Ack 할 때 까지 Advance 가 기다린다.
+// 받기를 그만 둘때는 Stop 해야 Advance 가 기다리지 않는다.
+func Ack(tm interface{ C() <-chan time.Time }) {
+	switch ft := tm.(type) {
+	case *fakeTimer:
+		ft.ack()
+	case fakeTicker:
+		ft.ack()
+	}
+}
+
+func (ft *fakeTimer) ack() {
+	ft.fake.mutex.Lock()
+	defer ft.fake.mutex.Unlock()
+	ft.acking = true
+	if ft.unacked > 0 {
+		ft.unacked--
+		ft.fake.unacked--
+	}
+	ft.fake.acked.Broadcast()
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (ft fakeTicker) Stop() {
+	ft.fakeTimer.Stop()
+}
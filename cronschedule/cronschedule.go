@@ -0,0 +1,141 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// cron 표현식으로 humantimetask 의 다음 실행 시간을 계산
+package cronschedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasworld/timedtask/humantimetask"
+)
+
+var _ humantimetask.Schedule = &Schedule{}
+
+// 이 기간 안에 맞는 시간이 없으면 (2월 30일 등) 없는것으로 본다.
+const searchYears = 5
+
+// 각 field 는 허용 되는 값의 bit set
+type Schedule struct {
+	spec   string
+	second uint64
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// dom, dow 가 둘다 * 가 아니면 둘중 하나만 맞아도 된다.
+	domStar bool
+	dowStar bool
+
+	loc *time.Location // nil : Next 에 주어진 시간의 location
+}
+
+func (cs Schedule) String() string {
+	if cs.loc != nil {
+		return fmt.Sprintf("Cron[%v %v]", cs.spec, cs.loc)
+	}
+	return fmt.Sprintf("Cron[%v]", cs.spec)
+}
+
+func (cs *Schedule) Location() *time.Location {
+	return cs.loc
+}
+
+// prev 이후 (prev 는 제외) 의 첫 실행 시간, 없으면 zero time
+// DST 로 없는 시간은 건너뛰고 두번 나오는 시간은 두번 실행한다.
+// 정해진 처리가 필요하면 calendarschedule 을 쓴다.
+func (cs *Schedule) Next(prev time.Time) time.Time {
+	loc := cs.loc
+	if loc == nil {
+		loc = prev.Location()
+	}
+	t := prev.In(loc).Truncate(time.Second).Add(time.Second)
+	yearLimit := t.Year() + searchYears
+
+	// 큰 단위 부터 맞추고, 바뀌면 아래 단위는 처음 값으로 돌린다.
+	added := false
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for !hasBit(cs.month, int(t.Month())) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !cs.dayMatch(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for !hasBit(cs.hour, t.Hour()) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for !hasBit(cs.minute, t.Minute()) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for !hasBit(cs.second, t.Second()) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+func (cs *Schedule) dayMatch(t time.Time) bool {
+	domMatch := hasBit(cs.dom, t.Day())
+	dowMatch := hasBit(cs.dow, int(t.Weekday()))
+	if cs.domStar || cs.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func hasBit(bits uint64, n int) bool {
+	return bits&(1<<uint(n)) != 0
+}
@@ -0,0 +1,55 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronschedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	base := time.Date(2019, 5, 2, 10, 7, 30, 0, time.UTC) // Thursday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2019, 5, 2, 10, 15, 0, 0, time.UTC)},
+		{"0 5 * * mon", time.Date(2019, 5, 6, 5, 0, 0, 0, time.UTC)},
+		{"30 * * * * *", time.Date(2019, 5, 2, 10, 8, 30, 0, time.UTC)},
+		{"@daily", time.Date(2019, 5, 3, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2019, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 5", time.Date(2019, 5, 3, 0, 0, 0, 0, time.UTC)}, // dom or dow
+	}
+	for _, tt := range tests {
+		cs, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("%v %v", tt.spec, err)
+			continue
+		}
+		if got := cs.Next(base); !got.Equal(tt.want) {
+			t.Errorf("%v Next %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	for _, spec := range []string{
+		"", "* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "@never",
+		"0 0 30 2 *", "0 0 31 4,6,9,11 *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q parsed", spec)
+		}
+	}
+}
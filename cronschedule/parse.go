@@ -0,0 +1,229 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronschedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type fieldRange struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	secondRange = fieldRange{name: "second", min: 0, max: 59}
+	minuteRange = fieldRange{name: "minute", min: 0, max: 59}
+	hourRange   = fieldRange{name: "hour", min: 0, max: 23}
+	domRange    = fieldRange{name: "day of month", min: 1, max: 31}
+	monthRange  = fieldRange{name: "month", min: 1, max: 12,
+		names: map[string]int{
+			"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+			"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+		}}
+	// 7 도 일요일로 받는다.
+	dowRange = fieldRange{name: "day of week", min: 0, max: 7,
+		names: map[string]int{
+			"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+		}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// spec 형식
+//
+//	minute hour dom month dow         (5 field, second 는 0)
+//	second minute hour dom month dow  (6 field)
+//	@yearly @annually @monthly @weekly @daily @midnight @hourly
+//
+// 각 field 는 * ? a a-b */n a-b/n a,b,c 와 jan-dec, sun-sat 이름을 쓸수 있다.
+// 시간은 Next 에 주어진 시간의 location 으로 계산한다.
+func Parse(spec string) (*Schedule, error) {
+	return ParseInLocation(spec, nil)
+}
+
+// loc 의 시간으로 계산 한다. loc 가 nil 이면 Parse 와 같다.
+func ParseInLocation(spec string, loc *time.Location) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	fieldstr := spec
+	if strings.HasPrefix(spec, "@") {
+		expanded, exist := macros[strings.ToLower(spec)]
+		if !exist {
+			return nil, fmt.Errorf("unknown cron macro %q", spec)
+		}
+		fieldstr = expanded
+	}
+	fields := strings.Fields(fieldstr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron %q need 5 or 6 fields, got %v", spec, len(fields))
+	}
+
+	cs := &Schedule{
+		spec: spec,
+		loc:  loc,
+	}
+	var err error
+	if cs.second, _, err = parseField(fields[0], secondRange); err != nil {
+		return nil, fmt.Errorf("cron %q %v", spec, err)
+	}
+	if cs.minute, _, err = parseField(fields[1], minuteRange); err != nil {
+		return nil, fmt.Errorf("cron %q %v", spec, err)
+	}
+	if cs.hour, _, err = parseField(fields[2], hourRange); err != nil {
+		return nil, fmt.Errorf("cron %q %v", spec, err)
+	}
+	if cs.dom, cs.domStar, err = parseField(fields[3], domRange); err != nil {
+		return nil, fmt.Errorf("cron %q %v", spec, err)
+	}
+	if cs.month, _, err = parseField(fields[4], monthRange); err != nil {
+		return nil, fmt.Errorf("cron %q %v", spec, err)
+	}
+	if cs.dow, cs.dowStar, err = parseField(fields[5], dowRange); err != nil {
+		return nil, fmt.Errorf("cron %q %v", spec, err)
+	}
+	if hasBit(cs.dow, 7) {
+		cs.dow |= 1 << 0
+	}
+	if !cs.hasDay() {
+		return nil, fmt.Errorf("cron %q never matches", spec)
+	}
+	return cs, nil
+}
+
+// 2월 29일 처럼 윤년에만 있는 날도 있는 날로 본다.
+var monthMaxDay = [...]int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+// month 중에 dom 이 있는 달이 있는지, dow 도 정했으면 dow 로 맞출수 있다.
+func (cs *Schedule) hasDay() bool {
+	if cs.domStar || !cs.dowStar {
+		return true
+	}
+	for m := monthRange.min; m <= monthRange.max; m++ {
+		if !hasBit(cs.month, m) {
+			continue
+		}
+		for d := domRange.min; d <= monthMaxDay[m-1]; d++ {
+			if hasBit(cs.dom, d) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func MustParse(spec string) *Schedule {
+	cs, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return cs
+}
+
+// return bits, is * or ?
+func parseField(field string, fr fieldRange) (uint64, bool, error) {
+	var bits uint64
+	star := false
+	for _, part := range strings.Split(field, ",") {
+		b, isStar, err := parsePart(part, fr)
+		if err != nil {
+			return 0, false, err
+		}
+		bits |= b
+		star = star || isStar
+	}
+	return bits, star, nil
+}
+
+func parsePart(part string, fr fieldRange) (uint64, bool, error) {
+	rangestr, stepstr := part, ""
+	if i := strings.Index(part, "/"); i >= 0 {
+		rangestr, stepstr = part[:i], part[i+1:]
+	}
+
+	star := false
+	var begin, end int
+	switch rangestr {
+	case "*", "?":
+		star = stepstr == ""
+		begin, end = fr.min, fr.max
+		if fr.max == 7 { // dow * 는 0-6
+			end = 6
+		}
+	default:
+		var err error
+		if i := strings.Index(rangestr, "-"); i >= 0 {
+			if begin, err = fr.parseValue(rangestr[:i]); err != nil {
+				return 0, false, err
+			}
+			if end, err = fr.parseValue(rangestr[i+1:]); err != nil {
+				return 0, false, err
+			}
+		} else {
+			if begin, err = fr.parseValue(rangestr); err != nil {
+				return 0, false, err
+			}
+			end = begin
+			if stepstr != "" { // a/n 은 a-max/n
+				end = fr.max
+			}
+		}
+	}
+	if begin > end {
+		return 0, false, fmt.Errorf("%v range %q begin > end", fr.name, part)
+	}
+
+	step := 1
+	if stepstr != "" {
+		var err error
+		step, err = strconv.Atoi(stepstr)
+		if err != nil || step <= 0 {
+			return 0, false, fmt.Errorf("%v invalid step %q", fr.name, part)
+		}
+	}
+
+	var bits uint64
+	for i := begin; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits, star, nil
+}
+
+func (fr fieldRange) parseValue(s string) (int, error) {
+	if v, exist := fr.names[strings.ToLower(s)]; exist {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%v invalid value %q", fr.name, s)
+	}
+	if v < fr.min || v > fr.max {
+		return 0, fmt.Errorf("%v value %v out of range %v-%v", fr.name, v, fr.min, fr.max)
+	}
+	return v, nil
+}
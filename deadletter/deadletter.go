@@ -0,0 +1,154 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 실패 하거나 panic 한 task (gametask, humantask) 를 보관해서 나중에 살펴보고 다시 넣거나 버린다.
+package deadletter
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// store 안에서 유일한 값
+type EntryID uint64
+
+func (id EntryID) String() string {
+	return fmt.Sprintf("DL#%d", uint64(id))
+}
+
+type Entry[T any] struct {
+	ID       EntryID
+	Task     T
+	FnName   string
+	Argument interface{}
+	Err      error       // DoTaskFn 의 error, panic 이면 panic 을 감싼 error
+	Panic    interface{} // nil : panic 아님
+	Stack    []byte      // panic 한 곳의 stack
+	Attempt  int         // 실패할 때 까지 시도한 횟수
+	Time     time.Time   // 보관한 시간
+}
+
+func (e Entry[T]) String() string {
+	return fmt.Sprintf("DeadLetter[%v %v attempt %v %v]", e.ID, e.FnName, e.Attempt, e.Err)
+}
+
+func (e Entry[T]) IsPanic() bool {
+	return e.Panic != nil
+}
+
+type Store[T any] struct {
+	mutex   sync.Mutex
+	maxLen  int // 0 : no limit
+	lastID  EntryID
+	entries map[EntryID]*Entry[T]
+	dropped int64 // maxLen 이 넘어서 버린 수
+}
+
+// maxLen 이 넘으면 오래된 것 부터 버린다. 0 이면 제한 없음
+func New[T any](maxLen int) *Store[T] {
+	return &Store[T]{
+		maxLen:  maxLen,
+		entries: make(map[EntryID]*Entry[T]),
+	}
+}
+
+func (st *Store[T]) String() string {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return fmt.Sprintf("DeadLetterStore[%v dropped %v]", len(st.entries), st.dropped)
+}
+
+// ID, Time 을 정해서 보관 한다.
+func (st *Store[T]) Add(e Entry[T]) EntryID {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.lastID++
+	e.ID = st.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	st.entries[e.ID] = &e
+	if st.maxLen > 0 && len(st.entries) > st.maxLen {
+		oldest := e.ID
+		for id := range st.entries {
+			if id < oldest {
+				oldest = id
+			}
+		}
+		delete(st.entries, oldest)
+		st.dropped++
+	}
+	return e.ID
+}
+
+func (st *Store[T]) Len() int {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return len(st.entries)
+}
+
+func (st *Store[T]) Dropped() int64 {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.dropped
+}
+
+// 보관한 순서
+func (st *Store[T]) List() []Entry[T] {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	rtn := make([]Entry[T], 0, len(st.entries))
+	for _, e := range st.entries {
+		rtn = append(rtn, *e)
+	}
+	sort.Slice(rtn, func(i, j int) bool {
+		return rtn[i].ID < rtn[j].ID
+	})
+	return rtn
+}
+
+func (st *Store[T]) Get(id EntryID) (Entry[T], bool) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	e, exist := st.entries[id]
+	if !exist {
+		return Entry[T]{}, false
+	}
+	return *e, true
+}
+
+// 꺼내서 store 에서 지운다. 다시 넣거나 버릴때 쓴다.
+func (st *Store[T]) Take(id EntryID) (Entry[T], bool) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	e, exist := st.entries[id]
+	if !exist {
+		return Entry[T]{}, false
+	}
+	delete(st.entries, id)
+	return *e, true
+}
+
+func (st *Store[T]) Discard(id EntryID) bool {
+	_, exist := st.Take(id)
+	return exist
+}
+
+// 모두 버린다. 버린 수를 돌려준다.
+func (st *Store[T]) DiscardAll() int {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	n := len(st.entries)
+	st.entries = make(map[EntryID]*Entry[T])
+	return n
+}
@@ -0,0 +1,39 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deadletter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	st := New[string](2)
+	id1 := st.Add(Entry[string]{Task: "a", Err: errors.New("fail a")})
+	id2 := st.Add(Entry[string]{Task: "b", Panic: "boom", Attempt: 3})
+	if e, ok := st.Get(id2); !ok || !e.IsPanic() || e.Attempt != 3 || e.Time.IsZero() {
+		t.Errorf("Get %v %v", e, ok)
+	}
+	id3 := st.Add(Entry[string]{Task: "c"})
+	if _, ok := st.Get(id1); ok || st.Len() != 2 || st.Dropped() != 1 {
+		t.Errorf("maxLen not applied %v", st)
+	}
+	if l := st.List(); len(l) != 2 || l[0].ID != id2 || l[1].ID != id3 {
+		t.Errorf("List %v", l)
+	}
+	if e, ok := st.Take(id2); !ok || e.Task != "b" || st.Len() != 1 {
+		t.Errorf("Take %v %v", e, ok)
+	}
+	if st.Discard(id2) || !st.Discard(id3) || st.Len() != 0 {
+		t.Errorf("Discard %v", st)
+	}
+}
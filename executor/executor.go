@@ -0,0 +1,68 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 시간이 된 task (gametask, humantask) 를 어디서 실행 할지 정한다.
+package executor
+
+import (
+	"errors"
+)
+
+var ErrRejected = errors.New("executor rejected")
+
+// queue 는 꺼낸 task 의 실행을 run 으로 넘긴다.
+// run 은 한번 실행 해야 하고, 실행 하지 않을 run 이면 error 를 돌려준다.
+// run 이 끝나야 queue 의 FlushTaskTill 이 진행 된다.
+type Executor interface {
+	Execute(run func()) error
+}
+
+// task 마다 새 goroutine, queue 의 기본값
+type Goroutine struct{}
+
+func (Goroutine) Execute(run func()) error {
+	go run()
+	return nil
+}
+
+// queue 의 Run goroutine 에서 바로 실행한다. 실행이 끝날때 까지 다음 task 를 꺼내지 않는다.
+type Inline struct{}
+
+func (Inline) Execute(run func()) error {
+	run()
+	return nil
+}
+
+type Func func(run func()) error
+
+func (fn Func) Execute(run func()) error {
+	return fn(run)
+}
+
+// game loop 등 다른 goroutine 이 받아서 실행한다. 받을 때 까지 기다린다.
+type Channel chan<- func()
+
+func (ch Channel) Execute(run func()) error {
+	ch <- run
+	return nil
+}
+
+// 받을 자리가 없으면 기다리지 않고 ErrRejected
+type TryChannel chan<- func()
+
+func (ch TryChannel) Execute(run func()) error {
+	select {
+	case ch <- run:
+		return nil
+	default:
+		return ErrRejected
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExecutor(t *testing.T) {
+	ch := make(chan func(), 1)
+	ran := 0
+	run := func() { ran++ }
+	for _, ex := range []Executor{Inline{}, Channel(ch)} {
+		if err := ex.Execute(run); err != nil {
+			t.Errorf("%T %v", ex, err)
+		}
+	}
+	if err := TryChannel(ch).Execute(run); !errors.Is(err, ErrRejected) {
+		t.Errorf("TryChannel full %v", err)
+	}
+	(<-ch)()
+	if ran != 2 {
+		t.Errorf("ran %v", ran)
+	}
+	done := make(chan struct{})
+	if err := (Goroutine{}).Execute(func() { close(done) }); err != nil {
+		t.Errorf("Goroutine %v", err)
+	}
+	<-done
+}
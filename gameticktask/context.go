@@ -0,0 +1,125 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktask
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kasworld/gametick"
+)
+
+// queue 의 Run ctx 가 끝나거나, 실행중 Remove 되거나, deadline 이 지나면
+// ctx 가 취소 된다.
+type DoTaskCtxFn func(ctx context.Context, ft *Task) error
+
+type runState struct {
+	mutex   sync.Mutex
+	current *runOnce // not nil : running
+}
+
+// 한번 실행의 상태, 실행 마다 새로 만든다.
+type runOnce struct {
+	cancel context.CancelFunc
+	done   chan struct{} // 실행이 끝나면 닫힌다.
+	err    error         // done 이 닫힌 뒤에 읽는다.
+}
+
+func NewWithContext(frametick gametick.GameTick, argument interface{}, doTaskCtxFn DoTaskCtxFn) *Task {
+	ft := New(frametick, argument, func(ft *Task) error {
+		return doTaskCtxFn(context.Background(), ft)
+	})
+	ft.doTaskCtxFn = doTaskCtxFn
+	ft.fnName = funcName(doTaskCtxFn)
+	return ft
+}
+
+func (ft *Task) Deadline() time.Time {
+	return ft.deadline
+}
+
+// 실행중 이 시간이 지나면 ctx 가 취소 된다. zero 면 없음
+func (ft *Task) SetDeadline(deadline time.Time) {
+	ft.deadline = deadline
+}
+
+func (ft *Task) IsRunning() bool {
+	ft.run.mutex.Lock()
+	defer ft.run.mutex.Unlock()
+	return ft.run.current != nil
+}
+
+// 실행중이면 ctx 를 취소하고 true
+func (ft *Task) CancelRun() bool {
+	ft.run.mutex.Lock()
+	defer ft.run.mutex.Unlock()
+	if ft.run.current == nil {
+		return false
+	}
+	ft.run.current.cancel()
+	return true
+}
+
+// 실행중이면 실행이 끝나면 닫히는 chan, 아니면 nil
+// timeout 으로 기다리지 않은 실행이 끝났는지 queue 가 볼때 쓴다.
+func (ft *Task) RunDone() <-chan struct{} {
+	ft.run.mutex.Lock()
+	defer ft.run.mutex.Unlock()
+	if ft.run.current == nil {
+		return nil
+	}
+	return ft.run.current.done
+}
+
+// 실행중이면 실행이 끝나기를 기다려 그 결과를 돌려주는 함수, 아니면 nil
+// timeout 으로 기다리지 않은 실행이 panic 했는지 queue 가 볼때 쓴다.
+func (ft *Task) RunWaiter() func() error {
+	ft.run.mutex.Lock()
+	defer ft.run.mutex.Unlock()
+	ro := ft.run.current
+	if ro == nil {
+		return nil
+	}
+	return func() error {
+		<-ro.done
+		return ro.err
+	}
+}
+
+// 이번 실행의 ctx 와 실행이 끝나면 결과를 주고 부를 함수
+func (ft *Task) runContext(parent context.Context) (context.Context, func(error)) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if ft.deadline.IsZero() {
+		ctx, cancel = context.WithCancel(parent)
+	} else {
+		ctx, cancel = context.WithDeadline(parent, ft.deadline)
+	}
+	ro := &runOnce{cancel: cancel, done: make(chan struct{})}
+	ft.run.mutex.Lock()
+	ft.run.current = ro
+	ft.run.mutex.Unlock()
+	return ctx, func(err error) {
+		ft.run.mutex.Lock()
+		if ft.run.current == ro {
+			ft.run.current = nil
+		}
+		ft.run.mutex.Unlock()
+		cancel()
+		ro.err = err
+		close(ro.done)
+	}
+}
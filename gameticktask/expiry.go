@@ -0,0 +1,45 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktask
+
+import (
+	"errors"
+
+	"github.com/kasworld/gametick"
+)
+
+var ErrExpired = errors.New("task expired")
+
+// expiry 가 지나서 꺼낸 task 대신 불린다. queue 의 processTasks 에서 부르니 짧게 끝낸다.
+type ExpireFn func(*Task)
+
+func (ft *Task) Expiry() gametick.GameTick {
+	return ft.expiry
+}
+
+// expiry 가 지나서 queue 가 꺼내면 실행 하지 않고 onExpire 를 부른다.
+// 0 이면 expiry 없음, onExpire 는 nil 이어도 된다.
+func (ft *Task) SetExpiry(expiry gametick.GameTick, onExpire ExpireFn) {
+	ft.expiry = expiry
+	ft.onExpire = onExpire
+}
+
+func (ft *Task) IsExpired(now gametick.GameTick) bool {
+	return ft.expiry != 0 && now > ft.expiry
+}
+
+// onExpire 를 부른다.
+func (ft *Task) Expired() {
+	if ft.onExpire != nil {
+		ft.onExpire(ft)
+	}
+}
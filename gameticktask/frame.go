@@ -0,0 +1,33 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktask
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasworld/gametick"
+)
+
+// game loop 가 queue 를 한 frame 진행한 결과
+type FrameReport struct {
+	Tick    gametick.GameTick
+	Run     int           // 실행한 task 수
+	Skipped int           // expiry, misfire 로 실행 하지 않은 task 수
+	Errors  []error       // 실패한 task 의 error, 실행 순서
+	Elapsed time.Duration // 이 frame 에 쓴 시간
+}
+
+func (rp FrameReport) String() string {
+	return fmt.Sprintf("FrameReport[%v run %v skip %v err %v %v]",
+		rp.Tick, rp.Run, rp.Skipped, len(rp.Errors), rp.Elapsed)
+}
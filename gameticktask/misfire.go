@@ -0,0 +1,34 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktask
+
+import (
+	"github.com/kasworld/timedtask/misfire"
+)
+
+func (ft *Task) MisfirePolicy() misfire.Policy {
+	return ft.misfire
+}
+
+// misfire.Default 면 queue 의 기본값을 쓴다.
+func (ft *Task) SetMisfirePolicy(p misfire.Policy) {
+	ft.misfire = p
+}
+
+// popDelay 보다 늦게 꺼낸 task 에 p 를 적용한다.
+// gameticktask 는 반복이 없어서 Skip 이면 버리고 나머지는 지금 실행 한다.
+func (ft *Task) PrepareMisfire(p misfire.Policy) misfire.Action {
+	if p == misfire.Skip {
+		return misfire.ActionDrop
+	}
+	return misfire.ActionRun
+}
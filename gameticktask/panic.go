@@ -0,0 +1,73 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktask
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kasworld/timedtask/loggeri"
+)
+
+// DoTaskFn 이 panic 하면 RunWithStat 등이 돌려주는 error
+type PanicError struct {
+	Task      *Task
+	Recovered interface{}
+	Stack     []byte
+}
+
+func newPanicError(ft *Task, r interface{}, stack []byte) *PanicError {
+	return &PanicError{
+		Task:      ft,
+		Recovered: r,
+		Stack:     stack,
+	}
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("%v panic %v", pe.Task, pe.Recovered)
+}
+
+// err 가 DoTaskFn 의 panic 인지
+func IsPanic(err error) bool {
+	var pe *PanicError
+	return errors.As(err, &pe)
+}
+
+// queue 가 task 의 panic 을 처리하는 방법, task 를 실행한 queue 의 goroutine 에서 불린다.
+// timeout 뒤에 panic 하면 그 실행이 끝나기를 기다린 다른 goroutine 에서 불린다.
+// 실행이 끝난 정리를 한 뒤에 불리므로 RePanic 해도 rate limit, serial key 는 풀려 있다.
+type PanicHandler func(t *Task, recovered interface{}, stack []byte)
+
+// os.Stderr 에 쓴다. queue 의 기본값
+func StderrPanic(t *Task, recovered interface{}, stack []byte) {
+	writePanic(t, recovered, stack)
+}
+
+// logger 의 Error 로 남긴다.
+func LogPanic(l loggeri.LoggerI) PanicHandler {
+	return func(t *Task, recovered interface{}, stack []byte) {
+		l.Error("RecoverPanic %v\n\n%s\n\n%s", t.PanicString(), recovered, stack)
+	}
+}
+
+// *PanicError 로 다시 panic 한다. queue 의 Run 에서는 process 가 죽는다.
+func RePanic(t *Task, recovered interface{}, stack []byte) {
+	panic(newPanicError(t, recovered, stack))
+}
+
+// os.Stderr 에 쓰고 process 를 끝낸다.
+func TerminatePanic(t *Task, recovered interface{}, stack []byte) {
+	writePanic(t, recovered, stack)
+	os.Exit(2)
+}
@@ -0,0 +1,60 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktask
+
+import (
+	"time"
+
+	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/retrypolicy"
+)
+
+func (ft *Task) RetryPolicy() *retrypolicy.Policy {
+	return ft.retry
+}
+
+// nil 이면 queue 의 기본값을 쓴다.
+func (ft *Task) SetRetryPolicy(p *retrypolicy.Policy) {
+	ft.retry = p
+}
+
+// 실행중 이면 몇번째 시도인지, 1 부터
+func (ft *Task) Attempt() int {
+	return ft.attempt
+}
+
+// 실패한 실행을 다시 할지 정하고, 다시 하면 frametick 을 retry 시간으로 바꾼다.
+// task 에 policy 가 없으면 defaultPolicy 를 쓴다.
+// tickDuration 은 queue 의 tick 1 의 실제 시간, retry delay 를 올림 해서 tick 으로 바꾼다.
+func (ft *Task) PrepareRetry(err error, now gametick.GameTick, tickDuration time.Duration, defaultPolicy *retrypolicy.Policy) bool {
+	p := ft.retry
+	if p == nil {
+		p = defaultPolicy
+	}
+	if p == nil || !p.ShouldRetry(ft.attempt, err) {
+		return false
+	}
+	ft.frametick = now + delayTick(p.Delay(ft.attempt), tickDuration)
+	return true
+}
+
+func delayTick(d time.Duration, tickDuration time.Duration) gametick.GameTick {
+	if tickDuration <= 0 {
+		return gametick.FromTimeDurationToTickType(d)
+	}
+	return gametick.GameTick((d + tickDuration - 1) / tickDuration)
+}
+
+// 성공 했거나 retry 를 포기한 task 의 시도 횟수를 지운다.
+func (ft *Task) ResetAttempt() {
+	ft.attempt = 0
+}
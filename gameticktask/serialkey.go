@@ -0,0 +1,22 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktask
+
+// 같은 serial key 의 task 들은 queue 에서 겹쳐 실행 되지 않고 꺼낸 순서대로 실행 된다.
+func (ft *Task) SerialKey() string {
+	return ft.serialKey
+}
+
+// 예: player, entity id, "" 이면 없음, queue 에 넣기 전에 정한다.
+func (ft *Task) SetSerialKey(key string) {
+	ft.serialKey = key
+}
@@ -13,14 +13,19 @@
 package gameticktask
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"reflect"
 	"runtime"
 	"runtime/debug"
+	"sync/atomic"
 	"time"
 
 	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/misfire"
+	"github.com/kasworld/timedtask/retrypolicy"
+	"github.com/kasworld/timedtask/taskhandle"
 	"github.com/kasworld/timedtask/taskstat"
 )
 
@@ -28,33 +33,70 @@ import (
 
 const invalidTaskIndex = -1
 
+// queue 에 Push 할때 정해지는 process 안에서 유일한 값, 0 은 아직 없음
+type TaskID uint64
+
+var lastTaskID uint64
+
+func (id TaskID) String() string {
+	return fmt.Sprintf("#%d", uint64(id))
+}
+
 type DoTaskFn func(*Task) error
 
 type Task struct {
+	id        TaskID
 	fnName    string
 	argument  interface{}
 	doTaskFn  DoTaskFn          // Task do function
 	frametick gametick.GameTick // The frametick of the item in the queue.
+
+	checkArg func(interface{}) error // not nil : TypedTask 의 argument 형 검사
+
+	doTaskCtxFn DoTaskCtxFn   // not nil : doTaskFn 대신 실행
+	deadline    time.Time     // zero : no deadline
+	timeout     time.Duration // 0 : queue 의 기본값
+
+	retry   *retrypolicy.Policy // nil : queue 의 기본값
+	attempt int                 // 이번 실행이 몇번째 시도인지
+	run     *runState           // 실행중 상태
+	handle  *taskhandle.Handle  // nil : no handle
+	misfire misfire.Policy      // Default : queue 의 기본값
+
+	expiry   gametick.GameTick // 0 : no expiry
+	onExpire ExpireFn
+
+	label     string // "" : rate limit 은 fnName 으로
+	serialKey string // "" : 다른 task 와 겹쳐 실행 될수 있다.
+
+	priority int    // 같은 시간 이면 큰것 먼저
+	seq      uint64 // 같은 시간, priority 면 작은것 먼저, Push, Update 때 정해진다.
+
 	// The index is needed by update and is maintained by the heap.Interface methods.
 	index int // The index of the item in the heap.
 }
 
 func New(frametick gametick.GameTick, argument interface{}, doTaskFn DoTaskFn) *Task {
-	taskfnname := runtime.FuncForPC(reflect.ValueOf(doTaskFn).Pointer()).Name()
+	taskfnname := funcName(doTaskFn)
 	ft := Task{
 		fnName:    taskfnname,
 		doTaskFn:  doTaskFn,
 		frametick: frametick,
 		argument:  argument,
 		index:     invalidTaskIndex,
+		run:       &runState{},
 	}
 	return &ft
 }
 
+func funcName(fn interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
 func (ft Task) String() string {
 	return fmt.Sprintf(
-		"GameTickTask[%v at %v]",
-		ft.GetTaskFnName(), ft.frametick)
+		"GameTickTask[%v %v at %v]",
+		ft.id, ft.GetTaskFnName(), ft.frametick)
 }
 
 func (ft *Task) PanicString() string {
@@ -69,6 +111,11 @@ func (ft *Task) TaskGameTick() gametick.GameTick {
 	return ft.frametick
 }
 
+// queue 에 없는 task 만, queue 안의 task 는 UpdateTaskTick 을 쓴다.
+func (ft *Task) SetTaskGameTick(frametick gametick.GameTick) {
+	ft.frametick = frametick
+}
+
 func (ft *Task) Argument() interface{} {
 	return ft.argument
 }
@@ -77,18 +124,67 @@ func (ft *Task) GetTaskFnName() string {
 	return ft.fnName
 }
 
+func (ft *Task) Priority() int {
+	return ft.priority
+}
+
+// 같은 시간 task 중 큰것이 먼저 실행 된다. Push 전에 정한다.
+func (ft *Task) SetPriority(priority int) {
+	ft.priority = priority
+}
+
+func (ft *Task) ID() TaskID {
+	return ft.id
+}
+
+// ID 가 없으면 새로 정한다. queue 의 Push 에서 부른다.
+func (ft *Task) AssignID() TaskID {
+	if ft.id == 0 {
+		ft.id = TaskID(atomic.AddUint64(&lastTaskID, 1))
+	}
+	return ft.id
+}
+
 func (ft *Task) IsValid() bool {
 	return ft.index != invalidTaskIndex
 }
 
+// 지금 상태를 복사한 queue 밖의 task, ID 는 같다. dead letter 에 보관할때 쓴다.
+// 실행 상태와 handle 은 복사하지 않는다.
+func (ft *Task) Snapshot() *Task {
+	st := *ft
+	st.index = invalidTaskIndex
+	st.run = &runState{}
+	st.handle = nil
+	return &st
+}
+
 func (ft *Task) RunWithStat(ts *taskstat.StatObj) error {
-	defer RecoverPanic(ft)
+	return ft.RunWithContext(context.Background(), ts)
+}
+
+// DoTaskCtxFn 에 주는 ctx 는 parent 가 끝나거나, deadline 이나 timeout 이 지나거나,
+// CancelRun 을 부르면 취소 된다.
+func (ft *Task) RunWithContext(parent context.Context, ts *taskstat.StatObj) error {
+	return ft.RunWithTimeout(parent, ts, ft.timeout)
+}
 
-	// ts := taskStat.GetStatByFuncName(ft.GetTaskFnName())
-	err := ft.GetTaskFn()(ft)
+func (ft *Task) runFn(ctx context.Context, ts *taskstat.StatObj) (rtnErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ts.Panic()
+			rtnErr = newPanicError(ft, r, debug.Stack())
+		}
+	}()
+	var err error
+	if ft.doTaskCtxFn != nil {
+		err = ft.doTaskCtxFn(ctx, ft)
+	} else {
+		err = ft.GetTaskFn()(ft)
+	}
 	ts.Commit()
 	if err != nil {
-		return fmt.Errorf("%v %v", ft, err)
+		return fmt.Errorf("%v %w", ft, err)
 	} else {
 		ts.Success()
 	}
@@ -97,14 +193,18 @@ func (ft *Task) RunWithStat(ts *taskstat.StatObj) error {
 
 func RecoverPanic(obj *Task) {
 	if r := recover(); r != nil {
-		errMsg := fmt.Sprintf(
-			"RecoverPanic %v\n\n%v\n\n%s\n\n%s",
-			time.Now().UTC(),
-			obj.PanicString(),
-			r,
-			string(debug.Stack()))
-		os.Stderr.WriteString(errMsg)
-
-		// syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		writePanic(obj, r, debug.Stack())
 	}
 }
+
+func writePanic(obj *Task, r interface{}, stack []byte) {
+	errMsg := fmt.Sprintf(
+		"RecoverPanic %v\n\n%v\n\n%s\n\n%s",
+		time.Now().UTC(),
+		obj.PanicString(),
+		r,
+		string(stack))
+	os.Stderr.WriteString(errMsg)
+
+	// syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+}
@@ -12,7 +12,10 @@
 package gameticktask
 
 import (
+	"container/heap"
 	"testing"
+
+	"github.com/kasworld/gametick"
 )
 
 func TestTask_PanicString(t *testing.T) {
@@ -21,3 +24,72 @@ func TestTask_PanicString(t *testing.T) {
 	})
 	t.Logf("%v", tk.PanicString())
 }
+
+func TestTaskList_Order(t *testing.T) {
+	var tl TaskList
+	var ran []int
+	for i, tick := range []gametick.GameTick{20, 10, 10, 10, 10} {
+		n := i
+		tk := New(tick, nil, func(tt *Task) error {
+			ran = append(ran, n)
+			return nil
+		})
+		if i == 4 {
+			tk.SetPriority(1)
+		}
+		heap.Push(&tl, tk)
+	}
+	for tl.Len() > 0 {
+		heap.Pop(&tl).(*Task).GetTaskFn()(nil)
+	}
+	want := []int{4, 1, 2, 3, 0}
+	for i := range want {
+		if ran[i] != want[i] {
+			t.Fatalf("order %v, want %v", ran, want)
+		}
+	}
+}
+
+func TestTaskList_RePush(t *testing.T) {
+	var tl TaskList
+	a := New(10, nil, nil)
+	b := New(10, nil, nil)
+	c := New(20, nil, nil)
+	heap.Push(&tl, a)
+	heap.Push(&tl, b)
+	heap.Push(&tl, c)
+	if heap.Pop(&tl).(*Task) != a {
+		t.Fatalf("first pushed not first")
+	}
+	heap.Push(&tl, a) // retry, repeat 처럼 다시 넣는다.
+	if err := tl.Update(c, nil, 10, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for i, want := range []*Task{b, a, c} {
+		if got := heap.Pop(&tl).(*Task); got != want {
+			t.Errorf("pop %v %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestTypedTask(t *testing.T) {
+	type spawnArg struct {
+		monsterID int
+	}
+	var got int
+	tt := NewTyped(10, spawnArg{monsterID: 3}, func(tk *TypedTask[spawnArg]) error {
+		got = tk.Argument().monsterID
+		return nil
+	})
+	var tl TaskList
+	heap.Push(&tl, tt.Task)
+	if err := tl.Update(tt.Task, "wrong", 20, tt.GetTaskFn()); err == nil {
+		t.Errorf("wrong argument type updated")
+	}
+	if err := tl.Update(tt.Task, spawnArg{monsterID: 5}, 20, tt.GetTaskFn()); err != nil {
+		t.Errorf("%v", err)
+	}
+	if err := tt.GetTaskFn()(tt.Task); err != nil || got != 5 || tt.TaskGameTick() != 20 {
+		t.Errorf("typed run %v %v", got, err)
+	}
+}
@@ -14,19 +14,30 @@ package gameticktask
 import (
 	"container/heap"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/kasworld/gametick"
 )
 
 // heap implementation
 
+// 같은 시간 task 의 순서
+var lastTaskSeq uint64
+
 type TaskList []*Task
 
 func (fh TaskList) Len() int { return len(fh) }
 
+// 같은 frametick 이면 priority 가 큰것, 그 다음은 먼저 넣거나 시간을 바꾼 것이 먼저 Pop 된다.
 func (fh TaskList) Less(i, j int) bool {
-	// We want Pop to give us the highest, not lowest, frametick so we use greater than here.
-	return fh[i].frametick < fh[j].frametick
+	a, b := fh[i], fh[j]
+	if a.frametick != b.frametick {
+		return a.frametick < b.frametick
+	}
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
 }
 
 func (fh TaskList) Swap(i, j int) {
@@ -39,6 +50,7 @@ func (fh *TaskList) Push(x interface{}) {
 	n := len(*fh)
 	item := x.(*Task)
 	item.index = n
+	item.seq = atomic.AddUint64(&lastTaskSeq, 1) // repeat, retry 로 다시 넣어도 뒤에 선다.
 	*fh = append(*fh, item)
 }
 
@@ -61,6 +73,9 @@ func (fh *TaskList) Update(
 	if item.index == invalidTaskIndex {
 		return fmt.Errorf("not found item in queue: %v", item)
 	}
+	if err := item.checkArgument(argument); err != nil {
+		return err
+	}
 	oldtick := item.frametick
 
 	item.argument = argument
@@ -68,6 +83,7 @@ func (fh *TaskList) Update(
 	item.doTaskFn = fn
 
 	if oldtick != frametick {
+		item.seq = atomic.AddUint64(&lastTaskSeq, 1) // rescheduled
 		heap.Fix(fh, item.index)
 	}
 	return nil
@@ -0,0 +1,92 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktaskqueue
+
+import (
+	"fmt"
+
+	"github.com/kasworld/timedtask/executor"
+	"github.com/kasworld/timedtask/gameticktask"
+	"github.com/kasworld/timedtask/workerpool"
+)
+
+// processTasks 에서 꺼낸 task 를 ex 로 실행한다. nil 이면 task 마다 goroutine
+// SetWorkerPool 로 만든 pool 은 backlog 에 남은 task 를 실행하고 끝난다.
+func (tq *TaskQueue) SetExecutor(ex executor.Executor) {
+	tq.setExecutor(ex, nil)
+}
+
+func (tq *TaskQueue) GetExecutor() executor.Executor {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	if tq.executor == nil {
+		return executor.Goroutine{}
+	}
+	return tq.executor
+}
+
+// config.Size 개의 goroutine 으로 실행하는 pool 을 executor 로 쓴다.
+// Size 가 0 이면 pool 을 없애고 task 마다 goroutine 을 쓴다.
+func (tq *TaskQueue) SetWorkerPool(config workerpool.Config) {
+	if config.Size <= 0 {
+		tq.setExecutor(nil, nil)
+		return
+	}
+	pool := workerpool.New(config)
+	tq.setExecutor(pool, pool)
+}
+
+func (tq *TaskQueue) setExecutor(ex executor.Executor, pool *workerpool.Pool) {
+	tq.mutex.Lock()
+	old := tq.workerPool
+	tq.executor = ex
+	tq.workerPool = pool
+	tq.mutex.Unlock()
+	if old != nil {
+		go old.Close()
+	}
+}
+
+// SetWorkerPool 로 만든 pool, 없으면 nil
+func (tq *TaskQueue) GetWorkerPool() *workerpool.Pool {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	return tq.workerPool
+}
+
+// runStat 옆에 보일 pool 상태, pool 이 없으면 ""
+func (tq *TaskQueue) workerPoolString() string {
+	if tq.workerPool == nil {
+		return ""
+	}
+	return " " + tq.workerPool.String()
+}
+
+// task 를 executor 로 실행한다.
+func (tq *TaskQueue) dispatch(t *gameticktask.Task) {
+	tq.runTasksEndWaitGroup.Add(1)
+	if err := tq.GetExecutor().Execute(func() { tq.runWaitTask(t) }); err != nil {
+		tq.rejectTask(t, err)
+	}
+}
+
+// executor 가 받지 않은 task 를 실패한 task 로 끝낸다. retry policy 를 따른다.
+func (tq *TaskQueue) rejectTask(t *gameticktask.Task, reason error) {
+	defer tq.runTasksEndWaitGroup.Done()
+	tso := tq.taskStat.GetStatByFuncName(t.GetTaskFnName())
+	tso.Commit()
+	err := fmt.Errorf("%v %w", t, reason)
+	tq.log.Warn("%v", err)
+	tq.taskEnded(t, tso, err)
+	tq.limitEnded(t)
+	tq.serialEnded(t)
+}
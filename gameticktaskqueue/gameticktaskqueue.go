@@ -13,16 +13,26 @@
 package gameticktaskqueue
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/kasworld/actpersec"
 	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/deadletter"
+	"github.com/kasworld/timedtask/executor"
 	"github.com/kasworld/timedtask/gameticktask"
 	"github.com/kasworld/timedtask/gameticktaskqueuei"
 	"github.com/kasworld/timedtask/loggeri"
+	"github.com/kasworld/timedtask/misfire"
+	"github.com/kasworld/timedtask/ratelimit"
+	"github.com/kasworld/timedtask/retrypolicy"
+	"github.com/kasworld/timedtask/serialkey"
+	"github.com/kasworld/timedtask/taskdep"
 	"github.com/kasworld/timedtask/taskstat"
+	"github.com/kasworld/timedtask/ticksource"
+	"github.com/kasworld/timedtask/workerpool"
 )
 
 var _ gameticktaskqueuei.TaskQueueI = &TaskQueue{}
@@ -34,11 +44,30 @@ type TaskQueue struct {
 	runTasksEndWaitGroup sync.WaitGroup // 실행중인 task가 모두 끝났음을 보장
 	paused               bool
 	runStat              *actpersec.ActPerSec
+	runCtx               context.Context // Run 의 ctx, task 실행에 쓴다.
 	pQueue               gameticktask.TaskList
+	taskByID             map[gameticktask.TaskID]*gameticktask.Task // pushed, not ended
+	taskDep              *taskdep.Graph[gameticktask.TaskID]
+	rateLimit            *ratelimit.Limiter[*gameticktask.Task]
+	serialKey            *serialkey.Lanes[*gameticktask.Task]
+	handoffs             []handoff // rate limit, serial key 자리를 넘겨 받아 Run 이 실행할 task
+	wakeHandoff          chan struct{}
+	executor             executor.Executor // nil : executor.Goroutine
+	workerPool           *workerpool.Pool  // SetWorkerPool 로 만든 pool
 	Name                 string
 	repeatWait           time.Duration
+	tickSource           ticksource.TickSource
 	popDelay             gametick.GameTick
+	stepped              bool                      // Advance 로 움직이는 queue, advancedTick 을 지금으로 본다.
+	advancedTick         gametick.GameTick         // 마지막 Advance 의 tick
+	flushing             bool                      // FlushTaskTill 중, flushTick 을 지금으로 본다.
+	flushTick            gametick.GameTick         // FlushTaskTill 이 실행 하는 task 의 지금 tick
+	defaultTimeout       time.Duration             // 0 : no timeout
+	retryPolicy          *retrypolicy.Policy       // nil : no retry
+	panicHandler         gameticktask.PanicHandler // nil : gameticktask.StderrPanic
+	misfirePolicy        misfire.Policy            // Default : misfire.RunNow
 	taskStat             *taskstat.TaskStat
+	deadLetter           *deadletter.Store[*gameticktask.Task]
 }
 
 func New(
@@ -47,14 +76,32 @@ func New(
 	repeatWait time.Duration,
 	logger loggeri.LoggerI) *TaskQueue {
 
+	return NewWithTickSource(name, popDelay, repeatWait, logger, ticksource.Global{})
+}
+
+// ts 로 tick 을 읽고 기다린다. test 나 world 마다 다른 tick 은 ticksource.Manual
+func NewWithTickSource(
+	name string,
+	popDelay time.Duration,
+	repeatWait time.Duration,
+	logger loggeri.LoggerI,
+	ts ticksource.TickSource) *TaskQueue {
+
 	tq := &TaskQueue{
-		pQueue:     make(gameticktask.TaskList, 0),
-		Name:       name,
-		popDelay:   gametick.FromTimeDurationToTickType(popDelay),
-		repeatWait: repeatWait,
-		taskStat:   taskstat.New(),
-		runStat:    actpersec.New(),
-		log:        logger,
+		pQueue:      make(gameticktask.TaskList, 0),
+		taskByID:    make(map[gameticktask.TaskID]*gameticktask.Task),
+		taskDep:     taskdep.New[gameticktask.TaskID](),
+		rateLimit:   ratelimit.New[*gameticktask.Task](),
+		serialKey:   serialkey.New[*gameticktask.Task](),
+		wakeHandoff: make(chan struct{}, 1),
+		Name:        name,
+		tickSource:  ts,
+		popDelay:    ticksource.FromDuration(ts, popDelay),
+		repeatWait:  repeatWait,
+		taskStat:    taskstat.New(),
+		deadLetter:  deadletter.New[*gameticktask.Task](deadLetterMaxLen),
+		runStat:     actpersec.New(),
+		log:         logger,
 	}
 	return tq
 }
@@ -62,11 +109,39 @@ func New(
 func (tq TaskQueue) String() string {
 	if tq.paused {
 		return fmt.Sprintf(
-			"GameTickTaskQueue[%v paused %v %v]",
-			tq.Name, tq.Len(), tq.runStat)
+			"GameTickTaskQueue[%v paused %v %v%s]",
+			tq.Name, tq.Len(), tq.runStat, tq.workerPoolString())
 	} else {
 		return fmt.Sprintf(
-			"GameTickTaskQueue[%v running %v %v]",
-			tq.Name, tq.Len(), tq.runStat)
+			"GameTickTaskQueue[%v running %v %v%s]",
+			tq.Name, tq.Len(), tq.runStat, tq.workerPoolString())
 	}
 }
+
+// timeout 이 없는 task 의 실행 시간 제한, 넘으면 ctx 를 취소하고 기다리지 않는다.
+func (tq *TaskQueue) SetDefaultTimeout(timeout time.Duration) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.defaultTimeout = timeout
+}
+
+// 실패한 task 를 다시 실행하는 기본 policy, task 의 policy 가 우선 한다. nil 이면 다시 하지 않는다.
+func (tq *TaskQueue) SetRetryPolicy(p *retrypolicy.Policy) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.retryPolicy = p
+}
+
+// task 가 panic 하면 부른다. gameticktask.LogPanic, RePanic, TerminatePanic 이나 직접 만든 handler
+func (tq *TaskQueue) SetPanicHandler(h gameticktask.PanicHandler) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.panicHandler = h
+}
+
+// popDelay 보다 늦게 꺼낸 task 의 기본 처리, task 의 policy 가 우선 한다.
+func (tq *TaskQueue) SetMisfirePolicy(p misfire.Policy) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.misfirePolicy = p
+}
@@ -14,17 +14,31 @@ package gameticktaskqueue
 import (
 	"container/heap"
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/kasworld/gametick"
-	"github.com/kasworld/globalgametick"
 	"github.com/kasworld/timedtask/gameticktask"
+	"github.com/kasworld/timedtask/taskhandle"
+	"github.com/kasworld/timedtask/ticksource"
 )
 
 func (tq *TaskQueue) Run(ctx context.Context) {
 	tq.log.TraceService("Start Run %v", tq)
 	defer func() { tq.log.TraceService("End Run %v", tq) }()
 
+	tq.mutex.Lock()
+	tq.runCtx = ctx
+	tq.stepped = false // Run 으로 돌면 tickSource 의 tick 을 쓴다.
+	tq.mutex.Unlock()
+	defer func() { // Run 이 끝난 뒤의 FlushTaskTill 은 취소된 ctx 를 받지 않는다.
+		tq.mutex.Lock()
+		tq.runCtx = nil
+		tq.mutex.Unlock()
+	}()
+
+	chTick := tq.tickSource.Subscribe()
+	defer tq.tickSource.Unsubscribe(chTick)
 	chProcessTask := time.After(tq.repeatWait)
 	tk1sec := time.NewTicker(1 * time.Second)
 	defer tk1sec.Stop()
@@ -36,6 +50,15 @@ func (tq *TaskQueue) Run(ctx context.Context) {
 		case <-tk1sec.C:
 			tq.runStat.UpdateLap()
 
+		case <-tq.wakeHandoff:
+			tq.startHandoffs(tq.dispatch)
+
+		case <-chTick:
+			if !tq.IsPaused() {
+				nextWait := tq.processTasks()
+				chProcessTask = time.After(nextWait)
+			}
+
 		case <-chProcessTask:
 			if tq.IsPaused() {
 				chProcessTask = time.After(tq.repeatWait)
@@ -48,34 +71,56 @@ func (tq *TaskQueue) Run(ctx context.Context) {
 }
 
 func (tq *TaskQueue) processTasks() time.Duration {
-	startTick := globalgametick.GetGameTick()
-	repeatWaitTick := gametick.FromTimeDurationToTickType(tq.repeatWait)
+	tq.startHandoffs(tq.dispatch) // 자리를 넘겨 받아 기다리던 task 먼저
+	startTick := tq.tickSource.GetGameTick()
+	repeatWaitTick := ticksource.FromDuration(tq.tickSource, tq.repeatWait)
+	if repeatWaitTick < 1 {
+		repeatWaitTick = 1
+	}
 
 	for {
-		thisTick := globalgametick.GetGameTick()
+		thisTick := tq.tickSource.GetGameTick()
 
 		peeked := tq.Peek()
 		if peeked == nil { // no task to do
 			nextWait := repeatWaitTick - (thisTick - startTick)
-			return gametick.MakeIn(nextWait, 0, repeatWaitTick).ToTimeDuration()
+			return ticksource.ToDuration(tq.tickSource, gametick.MakeIn(nextWait, 0, repeatWaitTick))
 		}
 		if startTick < peeked.TaskGameTick() { // no current task
 			nextWait := peeked.TaskGameTick() - thisTick
-			return gametick.MakeIn(nextWait, 0, repeatWaitTick).ToTimeDuration()
+			return ticksource.ToDuration(tq.tickSource, gametick.MakeIn(nextWait, 0, repeatWaitTick))
 		}
 
-		t := tq.Pop()
+		t := tq.popToRun()
 		if t == nil {
 			continue
 		}
-		callDuration := thisTick - t.TaskGameTick()
-		if callDuration > tq.popDelay {
-			tq.log.Debug("%v Delayed Pop %v %v", tq, t, callDuration)
+		if tq.admit(t, thisTick) {
+			tq.dispatch(t)
 		}
+	}
+}
 
-		tq.runTasksEndWaitGroup.Add(1)
-		go tq.runWaitTask(t)
+// 꺼낸 task 를 지금 실행 해도 되면 true, 기다리게 하거나 버린 task 는 false.
+// Run 과 FlushTaskTill 이 같이 쓴다.
+func (tq *TaskQueue) admit(t *gameticktask.Task, now gametick.GameTick) bool {
+	if tq.holdForPrerequisite(t) {
+		return false
+	}
+	if tq.dropExpired(t, now) {
+		return false
+	}
+	callDuration := now - t.TaskGameTick()
+	if callDuration > tq.popDelay {
+		tq.log.Debug("%v Delayed Pop %v %v", tq, t, callDuration)
+		if !tq.applyMisfire(t) {
+			return false
+		}
 	}
+	if tq.deferByLimit(t, now) {
+		return false
+	}
+	return !tq.holdForSerialKey(t)
 }
 
 func (tq *TaskQueue) Pause() error {
@@ -123,7 +168,44 @@ func (tq *TaskQueue) Remove(t *gameticktask.Task) error {
 
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
-	return tq.pQueue.Remove(t)
+	if tq.removeWaiting(t) {
+		return nil
+	}
+	if !t.IsValid() && tq.taskByID[t.ID()] == t {
+		// running task, cancel ctx. taskEnded 에서 retry 하지 않고 끝낸다.
+		delete(tq.taskByID, t.ID())
+		t.CancelRun()
+		return nil
+	}
+	if err := tq.pQueue.Remove(t); err != nil {
+		return err
+	}
+	delete(tq.taskByID, t.ID())
+	t.Handle().Cancel()
+	tq.prerequisiteEnded(t, false)
+	return nil
+}
+
+func (tq *TaskQueue) GetByID(id gameticktask.TaskID) *gameticktask.Task {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	return tq.taskByID[id]
+}
+
+func (tq *TaskQueue) RemoveByID(id gameticktask.TaskID) error {
+	t := tq.GetByID(id)
+	if t == nil {
+		return fmt.Errorf("%v remove failed, not found task %v", tq, id)
+	}
+	return tq.Remove(t)
+}
+
+func (tq *TaskQueue) RescheduleByID(id gameticktask.TaskID, uptick gametick.GameTick) error {
+	t := tq.GetByID(id)
+	if t == nil {
+		return fmt.Errorf("%v reschedule failed, not found task %v", tq, id)
+	}
+	return tq.UpdateTaskTick(t, uptick)
 }
 
 func (tq *TaskQueue) Push(t *gameticktask.Task) {
@@ -133,8 +215,21 @@ func (tq *TaskQueue) Push(t *gameticktask.Task) {
 
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
+	tq.push(t)
+}
+
+// mutex 안에서 부른다.
+func (tq *TaskQueue) push(t *gameticktask.Task) {
 	if t.IsValid() {
 		tq.log.Fatal("%v tried to push %v already pushed", tq, t)
 	}
 	heap.Push(&tq.pQueue, t)
+	tq.taskByID[t.AssignID()] = t
+}
+
+// 끝나기를 기다리고 결과를 받을 handle 을 붙여서 넣는다.
+func (tq *TaskQueue) PushWithHandle(t *gameticktask.Task) *taskhandle.Handle {
+	h := t.AttachHandle()
+	tq.Push(t)
+	return h
 }
@@ -13,8 +13,223 @@
 package gameticktaskqueue
 
 import (
+	"errors"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/gameticktask"
+	"github.com/kasworld/timedtask/retrypolicy"
+	"github.com/kasworld/timedtask/simulation"
+	"github.com/kasworld/timedtask/taskdep"
+	"github.com/kasworld/timedtask/taskhandle"
+	"github.com/kasworld/timedtask/ticksource"
 )
 
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Fatal(format string, v ...interface{})        { l.t.Fatalf(format, v...) }
+func (l testLogger) Error(format string, v ...interface{})        { l.t.Logf(format, v...) }
+func (l testLogger) Warn(format string, v ...interface{})         { l.t.Logf(format, v...) }
+func (l testLogger) Debug(format string, v ...interface{})        {}
+func (l testLogger) TraceService(format string, v ...interface{}) {}
+
 func TestNew(t *testing.T) {
 }
+
+func TestTaskQueue_PushAfter(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	var ran []string
+	step := func(name string, err error) gameticktask.DoTaskFn {
+		return func(tt *gameticktask.Task) error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+	closeSeason := gameticktask.New(10, nil, step("close", nil))
+	ranking := gameticktask.New(5, nil, step("ranking", errors.New("fail")))
+	reward := gameticktask.New(5, nil, step("reward", nil))
+	notice := gameticktask.New(5, nil, step("notice", nil))
+	tq.Push(closeSeason)
+	if err := tq.PushAfter(ranking, taskdep.FailCancel, closeSeason); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := tq.PushAfter(reward, taskdep.FailCancel, ranking); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := tq.PushAfter(notice, taskdep.FailSkip, ranking); err != nil {
+		t.Fatalf("%v", err)
+	}
+	rewardHandle := reward.AttachHandle()
+	if err := tq.PushAfter(gameticktask.New(1, nil, step("x", nil)), taskdep.FailCancel, gameticktask.New(1, nil, nil)); err == nil {
+		t.Errorf("prerequisite not in queue")
+	}
+	tq.FlushTaskTill(100)
+	if len(ran) != 2 || ran[0] != "close" || ran[1] != "ranking" {
+		t.Errorf("ran %v", ran)
+	}
+	if rewardHandle.Status() != taskhandle.Cancelled || tq.Len() != 0 || tq.GetByID(notice.ID()) != nil {
+		t.Errorf("dependents not cancelled %v %v", rewardHandle, tq.Len())
+	}
+}
+
+func TestTaskQueue_Advance(t *testing.T) {
+	tq := New("test", time.Hour, time.Second, testLogger{t})
+	var ran []gametick.GameTick
+	step := func(tt *gameticktask.Task) error {
+		ran = append(ran, tt.TaskGameTick())
+		return nil
+	}
+	tq.Push(gameticktask.New(20, nil, func(tt *gameticktask.Task) error {
+		ran = append(ran, tt.TaskGameTick())
+		tq.Push(gameticktask.New(25, nil, step))
+		return nil
+	}))
+	tq.Push(gameticktask.New(10, nil, step))
+	tq.Push(gameticktask.New(40, nil, step))
+
+	rp := tq.Advance(15)
+	if rp.Tick != 15 || rp.Run != 1 || len(ran) != 1 || ran[0] != 10 {
+		t.Errorf("frame 15 %v %v", rp, ran)
+	}
+	rp = tq.Advance(30)
+	if rp.Run != 2 || len(ran) != 3 || ran[1] != 20 || ran[2] != 25 || tq.Len() != 1 {
+		t.Errorf("frame 30 %v %v, len %v", rp, ran, tq.Len())
+	}
+	tq.Pause()
+	if rp = tq.Advance(50); rp.Run != 0 || tq.Len() != 1 {
+		t.Errorf("paused frame %v", rp)
+	}
+}
+
+func TestTaskQueue_AdvanceTickZero(t *testing.T) {
+	tq := New("test", time.Hour, time.Second, testLogger{t})
+	tq.SetRetryPolicy(&retrypolicy.Policy{MaxAttempts: 2})
+	errFail := errors.New("fail")
+	var attempts []int
+	tq.Push(gameticktask.New(0, nil, func(tt *gameticktask.Task) error {
+		attempts = append(attempts, tt.Attempt())
+		return errFail
+	}))
+	rp := tq.Advance(0)
+	if rp.Run != 2 || len(rp.Errors) != 2 || len(attempts) != 2 || attempts[1] != 2 || tq.Len() != 0 {
+		t.Errorf("retry not in frame 0 %v %v, len %v", rp, attempts, tq.Len())
+	}
+}
+
+func TestTaskQueue_RetryTickSource(t *testing.T) {
+	ts := ticksource.NewManual(0, time.Second/60)
+	tq := NewWithTickSource("test", time.Hour, time.Second, testLogger{t}, ts)
+	tq.SetRetryPolicy(&retrypolicy.Policy{MaxAttempts: 2, BaseDelay: 100 * time.Millisecond})
+	var attempts []int
+	tq.Push(gameticktask.New(0, nil, func(tt *gameticktask.Task) error {
+		attempts = append(attempts, tt.Attempt())
+		return errors.New("fail")
+	}))
+	tq.Advance(0)
+	if next := tq.Peek(); next == nil || next.TaskGameTick() != 7 {
+		t.Fatalf("retry tick %v", next)
+	}
+	if rp := tq.Advance(6); rp.Run != 0 {
+		t.Errorf("retry before delay %v", rp)
+	}
+	if rp := tq.Advance(7); rp.Run != 1 || len(attempts) != 2 || attempts[1] != 2 {
+		t.Errorf("retry not run %v %v", rp, attempts)
+	}
+}
+
+func TestTaskQueue_SerialKey(t *testing.T) {
+	tq := New("test", time.Hour, time.Second, testLogger{t})
+	block := make(chan struct{})
+	otherDone := make(chan struct{})
+	var mutex sync.Mutex
+	var ran []int
+	inRun := 0
+	step := func(i int) gameticktask.DoTaskFn {
+		return func(tt *gameticktask.Task) error {
+			mutex.Lock()
+			inRun++
+			if inRun > 1 {
+				t.Errorf("task %v overlapped", i)
+			}
+			mutex.Unlock()
+			if i == 1 {
+				<-block
+			}
+			mutex.Lock()
+			inRun--
+			ran = append(ran, i)
+			mutex.Unlock()
+			return nil
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		tt := gameticktask.New(gametick.GameTick(i), nil, step(i))
+		tt.SetSerialKey("player1")
+		tq.Push(tt)
+	}
+	other := gameticktask.New(2, nil, func(tt *gameticktask.Task) error {
+		close(otherDone)
+		return nil
+	})
+	other.SetSerialKey("player2")
+	tq.Push(other)
+	tq.processTasks()
+	select {
+	case <-otherDone:
+	case <-time.After(time.Second):
+		t.Errorf("other key blocked")
+	}
+	close(block)
+	for i := 0; i < 2; i++ { // 끝날때 마다 다음 task 가 자리를 넘겨 받는다.
+		tq.runTasksEndWaitGroup.Wait()
+		tq.startHandoffs(tq.dispatch)
+	}
+	tq.runTasksEndWaitGroup.Wait()
+	if len(ran) != 3 || ran[0] != 1 || ran[1] != 2 || ran[2] != 3 {
+		t.Errorf("ran %v", ran)
+	}
+	if tq.serialKey.Len() != 0 {
+		t.Errorf("serial key not ended %v", tq.serialKey.Len())
+	}
+}
+
+func TestTaskQueue_Simulate(t *testing.T) {
+	if _, err := New("test", time.Hour, time.Second, testLogger{t}).Simulate(1000); !errors.Is(err, simulation.ErrRealClock) {
+		t.Errorf("simulate with global tick %v", err)
+	}
+	ts := ticksource.NewManual(0, 50*time.Millisecond)
+	tq := NewWithTickSource("test", time.Hour, time.Second, testLogger{t}, ts)
+	var ran []gametick.GameTick
+	var spawn gameticktask.DoTaskFn
+	spawn = func(tt *gameticktask.Task) error {
+		ran = append(ran, tt.TaskGameTick())
+		if len(ran) < 3 {
+			tq.Push(gameticktask.New(tt.TaskGameTick()+100, nil, spawn))
+		}
+		return nil
+	}
+	tq.Push(gameticktask.New(100, nil, spawn))
+	rp, err := tq.Simulate(1000)
+	if err != nil || rp.Stopped != simulation.StopEmpty || rp.Run != 3 || rp.To != 300 || rp.Steps != 3 {
+		t.Errorf("%v %v", rp, err)
+	}
+	if ts.GetGameTick() != 300 {
+		t.Errorf("tick source at %v", ts.GetGameTick())
+	}
+	if len(ran) != 3 || ran[0] != 100 || ran[2] != 300 {
+		t.Errorf("ran %v", ran)
+	}
+
+	tq.Push(gameticktask.New(2000, nil, spawn))
+	rp, err = tq.Simulate(1000)
+	if err != nil || rp.Stopped != simulation.StopEndTime || rp.Run != 0 || rp.From != 300 || rp.To != 1000 || tq.Len() != 1 {
+		t.Errorf("%v %v", rp, err)
+	}
+	if ts.GetGameTick() != 1000 {
+		t.Errorf("tick source at %v", ts.GetGameTick())
+	}
+}
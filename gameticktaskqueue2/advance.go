@@ -0,0 +1,88 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktaskqueue2
+
+import (
+	"time"
+
+	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/gameticktask"
+)
+
+// game loop 가 매 frame 부른다. Advance 로 쓰는 queue 는 Run 을 부르지 않는다.
+// tick 까지 시간이 된 task 를 이 goroutine 에서 시간 순서대로 모두 실행한다.
+// 실행 하는 동안 새로 시간이 된 task 도 실행한다.
+// timer, goroutine 을 쓰지 않으므로 task 의 timeout 은 적용 되지 않는다.
+// rate limit, serial key, executor 도 쓰지 않는다.
+func (tq *TaskQueue) Advance(tick gametick.GameTick) (rp gameticktask.FrameReport) {
+	rp.Tick = tick
+	startTime := time.Now()
+	defer func() { rp.Elapsed = time.Since(startTime) }()
+
+	tq.mutex.Lock()
+	tq.stepped = true
+	tq.advancedTick = tick
+	paused := tq.paused
+	tq.mutex.Unlock()
+	if paused {
+		return rp
+	}
+
+	for {
+		peeked := tq.Peek()
+		if peeked == nil || tick < peeked.TaskGameTick() {
+			return rp
+		}
+
+		t := tq.popToRun()
+		if t == nil {
+			continue
+		}
+		if tq.holdInPausedScope(t) {
+			continue
+		}
+		if tq.holdForPrerequisite(t) {
+			continue
+		}
+		if tq.dropExpired(t, tick) {
+			rp.Skipped++
+			continue
+		}
+		if tick-t.TaskGameTick() > tq.popDelay && !tq.applyMisfire(t) {
+			rp.Skipped++
+			continue
+		}
+
+		tq.runStat.Inc()
+		tso := tq.taskStat.GetStatByFuncName(t.GetTaskFnName())
+		t.Handle().Start()
+		err := t.RunWithTimeout(tq.runContext(), tso, 0)
+		if err != nil {
+			tq.log.Error("%v", err)
+			rp.Errors = append(rp.Errors, err)
+		}
+		tq.taskEnded(t, tso, err)
+		rp.Run++
+		tq.handlePanic(err) // 정리한 뒤에 부른다.
+	}
+}
+
+// FlushTaskTill 중이면 실행 하는 task 의 tick, Advance 로 움직이는 queue 면 마지막 Advance 의 tick, mutex 안에서 부른다.
+func (tq *TaskQueue) currentTick() gametick.GameTick {
+	if tq.flushing {
+		return tq.flushTick
+	}
+	if tq.stepped {
+		return tq.advancedTick
+	}
+	return tq.tickSource.GetGameTick()
+}
@@ -0,0 +1,34 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktaskqueue2
+
+import (
+	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/gameticktask"
+)
+
+// expiry 가 지난 task 면 실행 하지 않고 끝내고 true
+func (tq *TaskQueue) dropExpired(t *gameticktask.Task, now gametick.GameTick) bool {
+	if !t.IsExpired(now) {
+		return false
+	}
+	tq.taskStat.Expire(t.GetTaskFnName())
+	tq.mutex.Lock()
+	delete(tq.taskByID, t.ID())
+	tq.leaveScope(t)
+	t.Handle().CancelWithErr(gameticktask.ErrExpired)
+	tq.prerequisiteEnded(t, false)
+	tq.mutex.Unlock()
+	tq.log.Debug("%v drop expired %v, expiry %v", tq.Name, t, t.Expiry())
+	t.Expired()
+	return true
+}
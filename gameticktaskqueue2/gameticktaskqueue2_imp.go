@@ -13,6 +13,9 @@ package gameticktaskqueue2
 
 import (
 	"container/heap"
+	"context"
+	"errors"
+	"time"
 
 	"github.com/kasworld/gametick"
 	"github.com/kasworld/timedtask/gameticktask"
@@ -24,7 +27,19 @@ func (tq *TaskQueue) FlushTaskTill(till gametick.GameTick) {
 	processed := 0
 	tq.log.TraceService("Start FlushTaskTill %v", tq)
 	defer func() { tq.log.TraceService("End FlushTaskTill %v, %v", processed, tq) }()
+	defer func() {
+		tq.mutex.Lock()
+		tq.flushing = false
+		tq.mutex.Unlock()
+	}()
+	runNow := func(t *gameticktask.Task) {
+		tq.runTasksEndWaitGroup.Add(1)
+		tq.runWaitTask(t) // executor 를 거치지 않고 이 goroutine 에서 실행한다.
+		processed++
+	}
 	for {
+		for tq.startHandoffs(runNow) > 0 {
+		}
 		peeked := tq.Peek()
 		if peeked == nil { // no task to do
 			return
@@ -33,26 +48,135 @@ func (tq *TaskQueue) FlushTaskTill(till gametick.GameTick) {
 			return
 		}
 
-		t := tq.Pop()
+		t := tq.popToRun()
 		if t == nil {
 			continue
 		}
-		tq.runStat.Inc()
-		tso := tq.taskStat.GetStatByFuncName(t.GetTaskFnName())
-		if err := t.RunWithStat(tso); err != nil {
-			tq.log.Error("%v", err)
+		now := tq.flushNow(t, till)
+		if !tq.admit(t, now) {
+			continue
 		}
-		processed++
+		runNow(t)
 	}
 }
 
+// FlushTaskTill 이 t 를 실행 할때의 지금 tick, till 까지 미리 실행 하면 task 의 tick 이 지금이다.
+// tickSource 가 till 을 지났으면 till 에서 멈춘다.
+func (tq *TaskQueue) flushNow(t *gameticktask.Task, till gametick.GameTick) gametick.GameTick {
+	now := tq.tickSource.GetGameTick()
+	if now < t.TaskGameTick() {
+		now = t.TaskGameTick()
+	}
+	if now > till {
+		now = till
+	}
+	tq.mutex.Lock()
+	tq.flushing = true
+	tq.flushTick = now
+	tq.mutex.Unlock()
+	return now
+}
+
 func (tq *TaskQueue) runWaitTask(t *gameticktask.Task) {
 	defer tq.runTasksEndWaitGroup.Done()
 	tq.runStat.Inc()
 	tso := tq.taskStat.GetStatByFuncName(t.GetTaskFnName())
-	if err := t.RunWithStat(tso); err != nil {
+	t.Handle().Start()
+	err := t.RunWithTimeout(tq.runContext(), tso, tq.taskTimeout(t))
+	if err != nil {
 		tq.log.Error("%v", err)
 	}
+	tq.afterRun(t, func(lateErr error) {
+		if gameticktask.IsPanic(lateErr) { // timeout 뒤에 panic 했다.
+			err = lateErr
+			tq.log.Error("%v", err)
+		}
+		defer tq.handlePanic(err) // RePanic 이어도 정리는 끝낸다.
+		tq.taskEnded(t, tso, err)
+		tq.limitEnded(t)
+		tq.serialEnded(t)
+	})
+}
+
+// 실행이 끝난 task 를 end 로 정리한다.
+// timeout 으로 기다리지 않은 실행이 남아 있으면 그 실행이 끝난 뒤에 정리해야
+// retry, repeat 로 다시 넣은 task 가 남은 실행과 겹치지 않는다.
+// 남은 실행의 결과를 lateErr 로 준다.
+func (tq *TaskQueue) afterRun(t *gameticktask.Task, end func(lateErr error)) {
+	wait := t.RunWaiter()
+	if wait == nil {
+		end(nil)
+		return
+	}
+	go func() {
+		end(wait())
+	}()
+}
+
+// panic 으로 끝났으면 panic handler 를 부른다.
+func (tq *TaskQueue) handlePanic(err error) {
+	var pe *gameticktask.PanicError
+	if !errors.As(err, &pe) {
+		return
+	}
+	tq.mutex.RLock()
+	h := tq.panicHandler
+	tq.mutex.RUnlock()
+	if h == nil {
+		h = gameticktask.StderrPanic
+	}
+	h(pe.Task, pe.Recovered, pe.Stack)
+}
+
+// task 에 timeout 이 없으면 queue 의 기본값
+func (tq *TaskQueue) taskTimeout(t *gameticktask.Task) time.Duration {
+	if t.Timeout() > 0 {
+		return t.Timeout()
+	}
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	return tq.defaultTimeout
+}
+
+// processTasks 에서 실행하는 task 의 ctx, Run 중이 아니면 Background
+func (tq *TaskQueue) runContext() context.Context {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	if tq.runCtx == nil {
+		return context.Background()
+	}
+	return tq.runCtx
+}
+
+// 실행이 끝난 task 정리, 실패한 task 는 retry policy 에 따라 다시 넣는다.
+func (tq *TaskQueue) taskEnded(t *gameticktask.Task, tso *taskstat.StatObj, err error) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	if t.IsValid() { // pushed again in DoTaskFn
+		t.Handle().Requeue()
+		return
+	}
+	if tq.taskByID[t.ID()] != t { // removed while running
+		t.ResetAttempt()
+		t.Handle().Cancel()
+		tq.prerequisiteEnded(t, false)
+		tq.leaveScope(t)
+		return
+	}
+	if err != nil && t.PrepareRetry(err, tq.currentTick(), tq.tickSource.TickDuration(), tq.retryPolicy) {
+		tso.Retry()
+		t.Handle().Requeue()
+		tq.pushAndSchedule(t)
+		return
+	}
+	if err != nil {
+		tq.keepDeadLetter(t, err)
+	}
+	t.ResetAttempt()
+	t.Handle().Finish(err)
+	tq.prerequisiteEnded(t, err == nil)
+	delete(tq.taskByID, t.ID())
+	tq.leaveScope(t)
 }
 
 func (tq *TaskQueue) IsPaused() bool {
@@ -74,16 +198,33 @@ func (tq *TaskQueue) Peek() *gameticktask.Task {
 	return nil
 }
 
+// 꺼낸 task 는 queue 가 더 관리하지 않는다. GetByID 로 찾을수 없다.
+// 붙은 handle 은 Cancelled 로 끝난다.
 func (tq *TaskQueue) Pop() *gameticktask.Task {
 	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	t := tq.pop()
+	if t != nil {
+		delete(tq.taskByID, t.ID())
+		t.Handle().Cancel()
+		tq.leaveScope(t)
+	}
+	return t
+}
+
+// 실행 하려고 꺼낸다. 끝날때 까지 taskByID 에 남는다.
+func (tq *TaskQueue) popToRun() *gameticktask.Task {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	return tq.pop()
+}
+
+// mutex 안에서 부른다.
+func (tq *TaskQueue) pop() *gameticktask.Task {
 	if len(tq.pQueue) == 0 {
-		tq.mutex.Unlock()
 		return nil
 	}
-	t := tq.pQueue[0]
-	_ = heap.Pop(&tq.pQueue)
-	tq.mutex.Unlock()
-	return t
+	return heap.Pop(&tq.pQueue).(*gameticktask.Task)
 }
 
 func (tq *TaskQueue) Len() int {
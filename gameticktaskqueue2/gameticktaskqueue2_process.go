@@ -18,14 +18,27 @@ import (
 	"time"
 
 	"github.com/kasworld/gametick"
-	"github.com/kasworld/globalgametick"
 	"github.com/kasworld/timedtask/gameticktask"
+	"github.com/kasworld/timedtask/taskhandle"
+	"github.com/kasworld/timedtask/ticksource"
 )
 
 func (tq *TaskQueue) Run(ctx context.Context) {
 	tq.log.TraceService("Start Run %v", tq)
 	defer func() { tq.log.TraceService("End Run %v", tq) }()
 
+	tq.mutex.Lock()
+	tq.runCtx = ctx
+	tq.stepped = false // Run 으로 돌면 tickSource 의 tick 을 쓴다.
+	tq.mutex.Unlock()
+	defer func() { // Run 이 끝난 뒤의 FlushTaskTill 은 취소된 ctx 를 받지 않는다.
+		tq.mutex.Lock()
+		tq.runCtx = nil
+		tq.mutex.Unlock()
+	}()
+
+	chTick := tq.tickSource.Subscribe()
+	defer tq.tickSource.Unsubscribe(chTick)
 	tk1sec := time.NewTicker(1 * time.Second)
 	defer tk1sec.Stop()
 	for {
@@ -34,27 +47,39 @@ func (tq *TaskQueue) Run(ctx context.Context) {
 			return
 
 		case <-tq.tasktimer.C:
-			tq.processTasks()
+			tq.processAndSchedule()
 
-			tq.mutex.RLock()
-			if tq.paused {
-				tq.tasktimer.Reset(timeDurationYear)
-			} else {
-				tq.scheduleTimerAtRootTick()
-			}
-			tq.mutex.RUnlock()
+		case <-chTick:
+			tq.processAndSchedule()
 
 		case <-tk1sec.C:
 			tq.runStat.UpdateLap()
+
+		case <-tq.wakeHandoff:
+			tq.startHandoffs(tq.dispatch)
 		}
 	}
 }
 
+// 시간이 된 task 를 실행하고 다음 task 에 timer 를 맞춘다.
+func (tq *TaskQueue) processAndSchedule() {
+	tq.processTasks()
+
+	tq.mutex.RLock()
+	if tq.paused {
+		tq.tasktimer.Reset(timeDurationYear)
+	} else {
+		tq.scheduleTimerAtRootTick()
+	}
+	tq.mutex.RUnlock()
+}
+
 func (tq *TaskQueue) processTasks() {
-	startTick := globalgametick.GetGameTick()
+	tq.startHandoffs(tq.dispatch) // 자리를 넘겨 받아 기다리던 task 먼저
+	startTick := tq.tickSource.GetGameTick()
 
 	for {
-		thisTick := globalgametick.GetGameTick()
+		thisTick := tq.tickSource.GetGameTick()
 
 		peeked := tq.Peek()
 		if peeked == nil {
@@ -64,18 +89,39 @@ func (tq *TaskQueue) processTasks() {
 			return
 		}
 
-		t := tq.Pop()
+		t := tq.popToRun()
 		if t == nil {
 			continue
 		}
-		callDuration := thisTick - t.TaskGameTick()
-		if callDuration > tq.popDelay {
-			tq.log.Debug("%v Delayed Pop %v %v", tq, t, callDuration)
+		if tq.admit(t, thisTick) {
+			tq.dispatch(t)
 		}
+	}
+}
 
-		tq.runTasksEndWaitGroup.Add(1)
-		go tq.runWaitTask(t)
+// 꺼낸 task 를 지금 실행 해도 되면 true, 기다리게 하거나 버린 task 는 false.
+// Run 과 FlushTaskTill 이 같이 쓴다.
+func (tq *TaskQueue) admit(t *gameticktask.Task, now gametick.GameTick) bool {
+	if tq.holdInPausedScope(t) {
+		return false
+	}
+	if tq.holdForPrerequisite(t) {
+		return false
+	}
+	if tq.dropExpired(t, now) {
+		return false
+	}
+	callDuration := now - t.TaskGameTick()
+	if callDuration > tq.popDelay {
+		tq.log.Debug("%v Delayed Pop %v %v", tq, t, callDuration)
+		if !tq.applyMisfire(t) {
+			return false
+		}
 	}
+	if tq.deferByLimit(t, now) {
+		return false
+	}
+	return !tq.holdForSerialKey(t)
 }
 
 func (tq *TaskQueue) scheduleTimerAtRootTick() {
@@ -85,11 +131,19 @@ func (tq *TaskQueue) scheduleTimerAtRootTick() {
 	d := timeDurationYear
 	if len(tq.pQueue) > 0 {
 		t := tq.pQueue[0].TaskGameTick()
-		d = (t - globalgametick.GetGameTick()).ToTimeDuration()
+		d = ticksource.ToDuration(tq.tickSource, t-tq.tickSource.GetGameTick())
 	}
 	tq.tasktimer.Reset(d)
 }
 
+// 다시 넣은 task 가 root 가 되면 timer 를 다시 맞춘다.
+func (tq *TaskQueue) pushAndSchedule(t *gameticktask.Task) {
+	heap.Push(&tq.pQueue, t)
+	if tq.pQueue[0] == t {
+		tq.scheduleTimerAtRootTick()
+	}
+}
+
 func (tq *TaskQueue) Pause() error {
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
@@ -161,11 +215,37 @@ func (tq *TaskQueue) Remove(t *gameticktask.Task) error {
 
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
+	return tq.remove(t)
+}
+
+func (tq *TaskQueue) remove(t *gameticktask.Task) error {
+	if !t.IsValid() {
+		if tq.removeWaiting(t) {
+			return nil
+		}
+		if tq.removeHeld(t) {
+			delete(tq.taskByID, t.ID())
+			t.Handle().Cancel()
+			tq.prerequisiteEnded(t, false)
+			tq.leaveScope(t)
+			return nil
+		}
+		if tq.taskByID[t.ID()] == t {
+			// running task, cancel ctx. taskEnded 에서 retry 하지 않고 끝낸다.
+			delete(tq.taskByID, t.ID())
+			t.CancelRun()
+			return nil
+		}
+	}
 	if len(tq.pQueue) > 0 {
 		oldroot := tq.pQueue[0]
 		if err := tq.pQueue.Remove(t); err != nil {
 			return err
 		}
+		delete(tq.taskByID, t.ID())
+		t.Handle().Cancel()
+		tq.prerequisiteEnded(t, false)
+		tq.leaveScope(t)
 		if oldroot == t {
 			tq.scheduleTimerAtRootTick()
 		}
@@ -175,6 +255,28 @@ func (tq *TaskQueue) Remove(t *gameticktask.Task) error {
 	return nil
 }
 
+func (tq *TaskQueue) GetByID(id gameticktask.TaskID) *gameticktask.Task {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	return tq.taskByID[id]
+}
+
+func (tq *TaskQueue) RemoveByID(id gameticktask.TaskID) error {
+	t := tq.GetByID(id)
+	if t == nil {
+		return fmt.Errorf("%v remove failed, not found task %v", tq, id)
+	}
+	return tq.Remove(t)
+}
+
+func (tq *TaskQueue) RescheduleByID(id gameticktask.TaskID, uptick gametick.GameTick) error {
+	t := tq.GetByID(id)
+	if t == nil {
+		return fmt.Errorf("%v reschedule failed, not found task %v", tq, id)
+	}
+	return tq.UpdateTaskTick(t, uptick)
+}
+
 func (tq *TaskQueue) Push(t *gameticktask.Task) {
 	if t == nil {
 		tq.log.Fatal("%v tried to push nil task", tq)
@@ -182,12 +284,24 @@ func (tq *TaskQueue) Push(t *gameticktask.Task) {
 
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
+	tq.push(t)
+}
 
+// mutex 안에서 부른다.
+func (tq *TaskQueue) push(t *gameticktask.Task) {
 	if t.IsValid() {
 		tq.log.Fatal("%v tried to push %v already pushed", tq, t)
 	}
 	heap.Push(&tq.pQueue, t)
+	tq.taskByID[t.AssignID()] = t
 	if tq.pQueue[0] == t {
 		tq.scheduleTimerAtRootTick()
 	}
 }
+
+// 끝나기를 기다리고 결과를 받을 handle 을 붙여서 넣는다.
+func (tq *TaskQueue) PushWithHandle(t *gameticktask.Task) *taskhandle.Handle {
+	h := t.AttachHandle()
+	tq.Push(t)
+	return h
+}
@@ -13,8 +13,234 @@
 package gameticktaskqueue2
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/gameticktask"
+	"github.com/kasworld/timedtask/retrypolicy"
+	"github.com/kasworld/timedtask/taskhandle"
+	"github.com/kasworld/timedtask/ticksource"
 )
 
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Fatal(format string, v ...interface{})        { l.t.Fatalf(format, v...) }
+func (l testLogger) Error(format string, v ...interface{})        { l.t.Logf(format, v...) }
+func (l testLogger) Warn(format string, v ...interface{})         { l.t.Logf(format, v...) }
+func (l testLogger) Debug(format string, v ...interface{})        {}
+func (l testLogger) TraceService(format string, v ...interface{}) {}
+
 func TestNew(t *testing.T) {
 }
+
+func TestTaskQueue_ByID(t *testing.T) {
+	tq := New("test", time.Second, testLogger{t})
+	var ran []gametick.GameTick
+	fn := func(tt *gameticktask.Task) error {
+		ran = append(ran, tt.TaskGameTick())
+		return nil
+	}
+	t1 := gameticktask.New(10, nil, fn)
+	t2 := gameticktask.New(20, nil, fn)
+	tq.Push(t1)
+	tq.Push(t2)
+	if t1.ID() == 0 || t1.ID() == t2.ID() {
+		t.Fatalf("invalid id %v %v", t1.ID(), t2.ID())
+	}
+	if tq.GetByID(t2.ID()) != t2 {
+		t.Errorf("GetByID fail")
+	}
+	if err := tq.RescheduleByID(t2.ID(), 5); err != nil {
+		t.Errorf("%v", err)
+	}
+	if err := tq.RemoveByID(t1.ID()); err != nil {
+		t.Errorf("%v", err)
+	}
+	if err := tq.RemoveByID(t1.ID()); err == nil {
+		t.Errorf("removed twice")
+	}
+	tq.FlushTaskTill(100)
+	if len(ran) != 1 || ran[0] != 5 {
+		t.Errorf("ran %v", ran)
+	}
+	if tq.GetByID(t2.ID()) != nil {
+		t.Errorf("ended task found")
+	}
+
+	t3 := gameticktask.New(30, nil, fn)
+	h3 := tq.PushWithHandle(t3)
+	if tq.Pop() != t3 || tq.GetByID(t3.ID()) != nil {
+		t.Errorf("popped task found")
+	}
+	if err := h3.Wait(context.Background()); !errors.Is(err, taskhandle.ErrCancelled) || h3.Status() != taskhandle.Cancelled {
+		t.Errorf("popped handle %v %v", err, h3)
+	}
+}
+
+func TestTaskQueue_Scope(t *testing.T) {
+	tq := New("test", time.Second, testLogger{t})
+	ran := 0
+	fn := func(tt *gameticktask.Task) error {
+		ran++
+		return nil
+	}
+	player := tq.NewScope("player", nil)
+	dungeon := tq.NewScope("dungeon", player)
+	tq.PushInScope(player, gameticktask.New(10, nil, fn))
+	tq.PushInScope(dungeon, gameticktask.New(20, nil, fn))
+	tq.PushInScope(dungeon, gameticktask.New(30, nil, fn))
+	if n := tq.ScopeLen(player); n != 3 {
+		t.Errorf("ScopeLen %v", n)
+	}
+
+	tq.PauseScope(player)
+	tq.FlushTaskTill(20)
+	if ran != 0 || tq.ScopeLen(dungeon) != 2 {
+		t.Errorf("paused scope run %v %v", ran, tq.ScopeLen(dungeon))
+	}
+	tq.ResumeScope(player)
+	tq.FlushTaskTill(20)
+	if ran != 2 {
+		t.Errorf("resumed scope run %v", ran)
+	}
+
+	if n := tq.CancelScope(player); n != 1 {
+		t.Errorf("CancelScope %v", n)
+	}
+	if tq.Len() != 0 || tq.ScopeLen(player) != 0 {
+		t.Errorf("task left after cancel %v", tq.Len())
+	}
+}
+
+func TestTaskQueue_CloseScope(t *testing.T) {
+	tq := New("test", time.Second, testLogger{t})
+	fn := func(tt *gameticktask.Task) error {
+		return nil
+	}
+	world := tq.NewScope("world", nil)
+	for i := 0; i < 3; i++ {
+		dungeon := tq.NewScope("dungeon", world)
+		tq.PushInScope(dungeon, gameticktask.New(10, nil, fn))
+		tq.NewScope("room", dungeon)
+		if n := len(tq.ScopeChildren(world)); n != 1 {
+			t.Fatalf("children %v", n)
+		}
+		if n := tq.CloseScope(dungeon); n != 1 {
+			t.Errorf("CloseScope %v", n)
+		}
+		if n := len(tq.ScopeChildren(world)); n != 0 {
+			t.Errorf("children after close %v", n)
+		}
+	}
+	if tq.Len() != 0 || tq.ScopeLen(world) != 0 {
+		t.Errorf("task left after close %v", tq.Len())
+	}
+}
+
+func TestTaskQueue_DeadLetter(t *testing.T) {
+	tq := New("test", time.Second, testLogger{t})
+	tq.SetPanicHandler(gameticktask.LogPanic(testLogger{t}))
+	fail := true
+	ran := 0
+	tk := gameticktask.New(10, "arg", func(tt *gameticktask.Task) error {
+		ran++
+		if fail {
+			panic("boom")
+		}
+		return nil
+	})
+	tq.Push(tk)
+	tq.FlushTaskTill(100)
+	l := tq.ListDeadLetter()
+	if len(l) != 1 || l[0].Task == tk || l[0].Task.ID() != tk.ID() || l[0].Argument != "arg" ||
+		l[0].Panic != "boom" || len(l[0].Stack) == 0 || l[0].Attempt != 1 {
+		t.Fatalf("dead letter %v", l)
+	}
+	fail = false
+	if err := tq.RequeueDeadLetter(l[0].ID, 200); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, exist := tq.GetDeadLetter(l[0].ID); exist || tq.Len() != 1 {
+		t.Errorf("requeue fail %v", tq.Len())
+	}
+	tq.FlushTaskTill(200)
+	if ran != 2 || len(tq.ListDeadLetter()) != 0 {
+		t.Errorf("ran %v, dead letter %v", ran, tq.ListDeadLetter())
+	}
+}
+
+func TestTaskQueue_Advance(t *testing.T) {
+	tq := New("test", time.Hour, testLogger{t})
+	var ran []gametick.GameTick
+	step := func(tt *gameticktask.Task) error {
+		ran = append(ran, tt.TaskGameTick())
+		return nil
+	}
+	errFail := errors.New("fail")
+	tq.Push(gameticktask.New(30, nil, func(tt *gameticktask.Task) error {
+		return errFail
+	}))
+	tq.Push(gameticktask.New(20, nil, func(tt *gameticktask.Task) error {
+		ran = append(ran, tt.TaskGameTick())
+		tq.Push(gameticktask.New(25, nil, step))
+		return nil
+	}))
+	tq.Push(gameticktask.New(10, nil, step))
+
+	rp := tq.Advance(15)
+	if rp.Run != 1 || len(rp.Errors) != 0 || len(ran) != 1 {
+		t.Errorf("frame 15 %v %v", rp, ran)
+	}
+	rp = tq.Advance(30)
+	if rp.Tick != 30 || rp.Run != 3 || len(rp.Errors) != 1 || !errors.Is(rp.Errors[0], errFail) {
+		t.Errorf("frame 30 %v", rp)
+	}
+	if len(ran) != 3 || ran[1] != 20 || ran[2] != 25 || tq.Len() != 0 {
+		t.Errorf("ran %v, len %v", ran, tq.Len())
+	}
+}
+
+func TestTaskQueue_AdvanceTickZero(t *testing.T) {
+	tq := New("test", time.Hour, testLogger{t})
+	tq.SetRetryPolicy(&retrypolicy.Policy{MaxAttempts: 2})
+	errFail := errors.New("fail")
+	var attempts []int
+	tq.Push(gameticktask.New(0, nil, func(tt *gameticktask.Task) error {
+		attempts = append(attempts, tt.Attempt())
+		return errFail
+	}))
+	rp := tq.Advance(0)
+	if rp.Run != 2 || len(rp.Errors) != 2 || len(attempts) != 2 || attempts[1] != 2 || tq.Len() != 0 {
+		t.Errorf("retry not in frame 0 %v %v, len %v", rp, attempts, tq.Len())
+	}
+}
+
+func TestTaskQueue_TickSource(t *testing.T) {
+	world1 := ticksource.NewManual(100, 50*time.Millisecond)
+	world2 := ticksource.NewManual(100, 50*time.Millisecond)
+	tq1 := NewWithTickSource("world1", time.Second, testLogger{t}, world1)
+	tq2 := NewWithTickSource("world2", time.Second, testLogger{t}, world2)
+	step := func(tt *gameticktask.Task) error { return nil }
+	h1 := tq1.PushWithHandle(gameticktask.New(110, nil, step))
+	h2 := tq2.PushWithHandle(gameticktask.New(110, nil, step))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go tq1.Run(ctx)
+	go tq2.Run(ctx)
+
+	for h1.Status() == taskhandle.Pending && ctx.Err() == nil {
+		world1.Advance(5)
+		time.Sleep(time.Millisecond)
+	}
+	if err := h1.Wait(ctx); err != nil || world1.GetGameTick() < 110 {
+		t.Errorf("world1 %v %v", err, h1)
+	}
+	if h2.Status() != taskhandle.Pending || tq2.Len() != 1 {
+		t.Errorf("world2 run without tick %v", h2)
+	}
+}
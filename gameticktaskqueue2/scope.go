@@ -0,0 +1,235 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktaskqueue2
+
+import (
+	"container/heap"
+	"fmt"
+
+	"github.com/kasworld/timedtask/gameticktask"
+)
+
+// 같이 취소, 멈춤 할 task 묶음 (player, dungeon instance 등), 중첩 할수 있다.
+// 모든 field 는 queue 의 mutex 로 보호 된다.
+type Scope struct {
+	name     string
+	parent   *Scope
+	children []*Scope
+	paused   bool
+	closed   bool                                       // CloseScope 로 닫혔으면 더 넣을수 없다.
+	tasks    map[gameticktask.TaskID]*gameticktask.Task // pushed, not ended
+	held     map[gameticktask.TaskID]*gameticktask.Task // due while paused
+}
+
+func (sc *Scope) String() string {
+	if sc.parent != nil {
+		return fmt.Sprintf("%v/%v", sc.parent, sc.name)
+	}
+	return sc.name
+}
+
+func (sc *Scope) Name() string {
+	return sc.name
+}
+
+func (sc *Scope) Parent() *Scope {
+	return sc.parent
+}
+
+// 자신이나 상위 scope 가 멈추었는지
+func (sc *Scope) isPaused() bool {
+	for s := sc; s != nil; s = s.parent {
+		if s.paused {
+			return true
+		}
+	}
+	return false
+}
+
+func (sc *Scope) walk(fn func(s *Scope)) {
+	fn(sc)
+	for _, child := range sc.children {
+		child.walk(fn)
+	}
+}
+
+// parent 가 nil 이면 최상위 scope
+func (tq *TaskQueue) NewScope(name string, parent *Scope) *Scope {
+	sc := &Scope{
+		name:   name,
+		parent: parent,
+		tasks:  make(map[gameticktask.TaskID]*gameticktask.Task),
+		held:   make(map[gameticktask.TaskID]*gameticktask.Task),
+	}
+	if parent != nil {
+		tq.mutex.Lock()
+		if parent.closed {
+			tq.log.Fatal("%v tried to make scope %v in closed scope %v", tq, name, parent)
+		}
+		parent.children = append(parent.children, sc)
+		tq.mutex.Unlock()
+	}
+	return sc
+}
+
+func (tq *TaskQueue) PushInScope(sc *Scope, t *gameticktask.Task) {
+	if sc == nil {
+		tq.log.Fatal("%v tried to push %v in nil scope", tq, t)
+	}
+	if t == nil {
+		tq.log.Fatal("%v tried to push nil task", tq)
+	}
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	if sc.closed {
+		tq.log.Fatal("%v tried to push %v in closed scope %v", tq, t, sc)
+	}
+	tq.push(t)
+	sc.tasks[t.ID()] = t
+	tq.taskScope[t.ID()] = sc
+}
+
+// scope 와 하위 scope 의 task 를 모두 지운다. 지운 task 수를 돌려준다.
+func (tq *TaskQueue) CancelScope(sc *Scope) int {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	removed := tq.cancelScope(sc)
+	tq.log.TraceService("%v cancel scope %v, %v tasks", tq, sc, removed)
+	return removed
+}
+
+// CancelScope 하고 parent 에서 떼어낸다. 하위 scope 도 같이 닫힌다.
+// 닫은 scope 에는 task 나 하위 scope 를 더 넣을수 없다.
+func (tq *TaskQueue) CloseScope(sc *Scope) int {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	removed := tq.cancelScope(sc)
+	sc.walk(func(s *Scope) {
+		s.closed = true
+	})
+	if p := sc.parent; p != nil {
+		for i, child := range p.children {
+			if child == sc {
+				p.children = append(p.children[:i], p.children[i+1:]...)
+				break
+			}
+		}
+	}
+	tq.log.TraceService("%v close scope %v, %v tasks", tq, sc, removed)
+	return removed
+}
+
+// mutex 안에서 부른다.
+func (tq *TaskQueue) cancelScope(sc *Scope) int {
+	var toRemove []*gameticktask.Task
+	sc.walk(func(s *Scope) {
+		for _, t := range s.tasks {
+			toRemove = append(toRemove, t)
+		}
+	})
+	removed := 0
+	for _, t := range toRemove {
+		if err := tq.remove(t); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// scope 와 하위 scope 의 task 는 시간이 되어도 ResumeScope 까지 실행 되지 않는다.
+func (tq *TaskQueue) PauseScope(sc *Scope) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	sc.paused = true
+}
+
+// 멈춘 동안 시간이 된 task 를 다시 넣는다.
+func (tq *TaskQueue) ResumeScope(sc *Scope) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	sc.paused = false
+	sc.walk(func(s *Scope) {
+		if s.isPaused() {
+			return
+		}
+		for id, t := range s.held {
+			delete(s.held, id)
+			heap.Push(&tq.pQueue, t)
+		}
+	})
+	tq.scheduleTimerAtRootTick()
+}
+
+// scope 와 하위 scope 의 끝나지 않은 task 수
+func (tq *TaskQueue) ScopeLen(sc *Scope) int {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	n := 0
+	sc.walk(func(s *Scope) {
+		n += len(s.tasks)
+	})
+	return n
+}
+
+// 닫히지 않은 바로 아래 scope 들
+func (tq *TaskQueue) ScopeChildren(sc *Scope) []*Scope {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	return append([]*Scope(nil), sc.children...)
+}
+
+// scope 와 하위 scope 의 끝나지 않은 task 들
+func (tq *TaskQueue) ScopeTasks(sc *Scope) []*gameticktask.Task {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	var rtn []*gameticktask.Task
+	sc.walk(func(s *Scope) {
+		for _, t := range s.tasks {
+			rtn = append(rtn, t)
+		}
+	})
+	return rtn
+}
+
+// 멈춘 scope 의 task 면 보관하고 true
+func (tq *TaskQueue) holdInPausedScope(t *gameticktask.Task) bool {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	sc := tq.taskScope[t.ID()]
+	if sc == nil || !sc.isPaused() {
+		return false
+	}
+	sc.held[t.ID()] = t
+	return true
+}
+
+// 보관중인 task 면 지우고 true
+func (tq *TaskQueue) removeHeld(t *gameticktask.Task) bool {
+	sc := tq.taskScope[t.ID()]
+	if sc == nil {
+		return false
+	}
+	if _, exist := sc.held[t.ID()]; !exist {
+		return false
+	}
+	delete(sc.held, t.ID())
+	return true
+}
+
+func (tq *TaskQueue) leaveScope(t *gameticktask.Task) {
+	sc := tq.taskScope[t.ID()]
+	if sc == nil {
+		return
+	}
+	delete(sc.tasks, t.ID())
+	delete(tq.taskScope, t.ID())
+}
@@ -0,0 +1,41 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktaskqueue2
+
+import (
+	"github.com/kasworld/timedtask/gameticktask"
+)
+
+// serial key 가 같은 task 가 실행중이면 t 를 기다리게 하고 true
+// rate limit 을 지난 뒤에 부른다.
+func (tq *TaskQueue) holdForSerialKey(t *gameticktask.Task) bool {
+	if t.SerialKey() == "" || tq.serialKey.TryStart(t.SerialKey(), t) {
+		return false
+	}
+	tq.log.Debug("%v hold %v, serial key %v running", tq.Name, t, t.SerialKey())
+	return true
+}
+
+// 같은 serial key 로 기다리던 다음 task 에 자리를 넘긴다.
+// 끝난 task 를 실행한 goroutine 에서 부르므로 넘겨 받은 task 는 Run 이 실행한다.
+func (tq *TaskQueue) serialEnded(t *gameticktask.Task) {
+	if t.SerialKey() == "" {
+		return
+	}
+	next, ok := tq.serialKey.End(t.SerialKey())
+	if !ok {
+		return
+	}
+	tq.mutex.Lock()
+	tq.handOff(next, true)
+	tq.mutex.Unlock()
+}
@@ -0,0 +1,73 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameticktaskqueue2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/simulation"
+	"github.com/kasworld/timedtask/ticksource"
+)
+
+// Run 대신 부른다. ticksource.Manual 로 만든 queue 에서만 쓴다.
+// 기다리지 않고 tick source 를 다음 task 의 tick 으로 옮기고 Advance 해 가며 순서대로 실행한다.
+// 다음 task 가 end 뒤이면 end 까지 옮기고 끝나고, task 가 없으면 그 tick 에 끝난다.
+// Advance 와 같이 task 의 timeout, rate limit, serial key, executor 는 쓰지 않는다.
+func (tq *TaskQueue) Simulate(end gametick.GameTick) (rp simulation.Report[gametick.GameTick], err error) {
+	manual, ok := tq.tickSource.(*ticksource.Manual)
+	if !ok {
+		return rp, fmt.Errorf("%v %w", tq, simulation.ErrRealClock)
+	}
+	startTime := time.Now()
+	tq.mutex.Lock()
+	rp.From = tq.currentTick()
+	tq.mutex.Unlock()
+	rp.To = rp.From
+	defer func() { rp.Elapsed = time.Since(startTime) }()
+
+	for {
+		if tq.IsPaused() {
+			rp.Stopped = simulation.StopPaused
+			return rp, nil
+		}
+		peeked := tq.Peek()
+		if peeked == nil {
+			rp.Stopped = simulation.StopEmpty
+			return rp, nil
+		}
+		next := peeked.TaskGameTick()
+		if next > end {
+			if end > rp.To {
+				if end > manual.GetGameTick() {
+					manual.Set(end)
+				}
+				tq.Advance(end)
+				rp.To = end
+			}
+			rp.Stopped = simulation.StopEndTime
+			return rp, nil
+		}
+		if next > rp.To {
+			rp.To = next
+			rp.Steps++
+		}
+		if rp.To > manual.GetGameTick() {
+			manual.Set(rp.To)
+		}
+		fr := tq.Advance(rp.To)
+		rp.Run += fr.Run
+		rp.Skipped += fr.Skipped
+		rp.Errors = append(rp.Errors, fr.Errors...)
+	}
+}
@@ -16,7 +16,10 @@ import (
 	"context"
 
 	"github.com/kasworld/gametick"
+	"github.com/kasworld/timedtask/deadletter"
 	"github.com/kasworld/timedtask/gameticktask"
+	"github.com/kasworld/timedtask/simulation"
+	"github.com/kasworld/timedtask/taskhandle"
 	"github.com/kasworld/timedtask/taskstat"
 )
 
@@ -26,8 +29,18 @@ type TaskQueueI interface {
 	GetTaskStat() *taskstat.TaskStat
 	UpdateTaskTick(t *gameticktask.Task, uptick gametick.GameTick) error
 	Remove(t *gameticktask.Task) error
+	GetByID(id gameticktask.TaskID) *gameticktask.Task
+	RemoveByID(id gameticktask.TaskID) error
+	RescheduleByID(id gameticktask.TaskID, uptick gametick.GameTick) error
+	ListDeadLetter() []deadletter.Entry[*gameticktask.Task]
+	GetDeadLetter(id deadletter.EntryID) (deadletter.Entry[*gameticktask.Task], bool)
+	RequeueDeadLetter(id deadletter.EntryID, uptick gametick.GameTick) error
+	DiscardDeadLetter(id deadletter.EntryID) bool
 	Push(t *gameticktask.Task)
+	PushWithHandle(t *gameticktask.Task) *taskhandle.Handle
 	Len() int
 	Run(ctx context.Context)
 	FlushTaskTill(till gametick.GameTick)
+	Advance(tick gametick.GameTick) gameticktask.FrameReport
+	Simulate(end gametick.GameTick) (simulation.Report[gametick.GameTick], error)
 }
@@ -0,0 +1,29 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"github.com/kasworld/timedtask/taskhandle"
+)
+
+// 붙은 handle 이 없으면 nil
+func (ft *Task) Handle() *taskhandle.Handle {
+	return ft.handle
+}
+
+// handle 이 없으면 새로 붙인다. Push 전에 부른다.
+func (ft *Task) AttachHandle() *taskhandle.Handle {
+	if ft.handle == nil {
+		ft.handle = taskhandle.New()
+	}
+	return ft.handle
+}
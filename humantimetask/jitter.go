@@ -0,0 +1,87 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// 같은 시간에 몰린 task 들을 흩어서 실행 하도록 tasktime 을 [base, base+Window) 안에서 늦춘다.
+type Jitter struct {
+	Window time.Duration // 0 : no jitter
+	Key    string        // not "" : key 의 hash 로 정해진 offset, "" : random offset
+}
+
+func (jt Jitter) String() string {
+	if jt.Key != "" {
+		return fmt.Sprintf("Jitter[%v %q]", jt.Window, jt.Key)
+	}
+	return fmt.Sprintf("Jitter[%v]", jt.Window)
+}
+
+func (jt Jitter) offset() time.Duration {
+	if jt.Window <= 0 {
+		return 0
+	}
+	if jt.Key != "" {
+		h := fnv.New64a()
+		h.Write([]byte(jt.Key))
+		return time.Duration(h.Sum64() % uint64(jt.Window))
+	}
+	return time.Duration(rand.Int63n(int64(jt.Window)))
+}
+
+func (ft *Task) GetJitter() Jitter {
+	return ft.jitter
+}
+
+// Window 가 0 이면 queue 의 기본값을 쓴다. Push 전에 정한다.
+func (ft *Task) SetJitter(jt Jitter) {
+	ft.jitter = jt
+}
+
+// jitter 를 적용 하기 전 시간
+func (ft *Task) BaseTime() time.Time {
+	return ft.tasktime.Add(-ft.jitterOffset)
+}
+
+// base 에 jitter 를 적용한 시간과 그 offset, task 는 바꾸지 않는다.
+// task 에 jitter 가 없으면 defaultJitter 를 쓴다. queue 가 Push, reschedule 할때 부른다.
+func (ft *Task) JitterTime(base time.Time, defaultJitter Jitter) (time.Time, time.Duration) {
+	jt := ft.jitter
+	if jt.Window <= 0 {
+		jt = defaultJitter
+	}
+	offset := jt.offset()
+	return base.Add(offset), offset
+}
+
+// tasktime 에 적용된 jitter, JitterTime 의 시간으로 Update 한 뒤에 부른다.
+// jitter 적용 전 시간이 새 예정 시간이 된다.
+func (ft *Task) SetJitterOffset(offset time.Duration) {
+	ft.jitterOffset = offset
+	ft.slot = ft.tasktime.Add(-offset)
+}
+
+// 적용 했던 jitter 를 지우고 다시 적용한다.
+func (ft *Task) ApplyJitter(defaultJitter Jitter) {
+	ft.tasktime, ft.jitterOffset = ft.JitterTime(ft.BaseTime(), defaultJitter)
+}
+
+// tasktime 을 jitter 적용 전으로 돌린다.
+func (ft *Task) clearJitter() {
+	ft.tasktime = ft.BaseTime()
+	ft.jitterOffset = 0
+}
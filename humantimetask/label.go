@@ -0,0 +1,42 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"time"
+)
+
+// 같은 label 의 task 들은 queue 의 rate limit 을 같이 쓴다.
+func (ft *Task) Label() string {
+	return ft.label
+}
+
+// "" 이면 함수 이름을 쓴다.
+func (ft *Task) SetLabel(label string) {
+	ft.label = label
+}
+
+// queue 의 rate limit 을 찾는 key, label 이 없으면 함수 이름
+func (ft *Task) LimitKey() string {
+	if ft.label != "" {
+		return ft.label
+	}
+	return ft.fnName
+}
+
+// rate limit 이나 선행 task 로 미룬 task 의 시간, 적용 했던 jitter 는 버린다.
+// 다음에 꺼낼 시간만 바꾸고 반복 시간을 정하는 예정 시간은 그대로 둔다.
+// queue 에 없는 task 에만 쓴다.
+func (ft *Task) DeferTo(tasktime time.Time) {
+	ft.jitterOffset = 0
+	ft.tasktime = tasktime
+}
@@ -0,0 +1,52 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"time"
+
+	"github.com/kasworld/timedtask/misfire"
+)
+
+func (ft *Task) MisfirePolicy() misfire.Policy {
+	return ft.misfire
+}
+
+// misfire.Default 면 queue 의 기본값을 쓴다.
+func (ft *Task) SetMisfirePolicy(p misfire.Policy) {
+	ft.misfire = p
+}
+
+// popDelay 보다 늦게 꺼낸 task 에 p 를 적용한다.
+// 반복 task 를 건너 뛰면 tasktime 을 now 이후의 다음 반복 시간으로 바꾼다.
+func (ft *Task) PrepareMisfire(now time.Time, p misfire.Policy) misfire.Action {
+	switch p {
+	case misfire.Skip:
+		if ft.SkipToNextRepeat(now) {
+			return misfire.ActionRequeue
+		}
+		return misfire.ActionDrop
+	case misfire.NextSlot:
+		if !ft.IsRepeat() || ft.repeatDone {
+			return misfire.ActionRun
+		}
+		if ft.SkipToNextRepeat(now) {
+			return misfire.ActionRequeue
+		}
+		return misfire.ActionDrop
+	case misfire.Coalesce:
+		ft.coalesce = ft.IsRepeat()
+		return misfire.ActionRun
+	default:
+		return misfire.ActionRun
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 반복 실행 task, queue 가 실행후 같은 Task 를 다시 넣어준다.
+
+// NewSchedule 의 sch 에 from 이후 실행 시간이 없다.
+var ErrNoOccurrence = errors.New("schedule has no occurrence")
+
+type RepeatMode int
+
+const (
+	// 예정 시간 기준 간격, 실행 시간과 무관하게 밀리지 않는다.
+	FixedRate RepeatMode = iota
+	// 실행이 끝난 시간 기준 간격
+	FixedDelay
+)
+
+func (rm RepeatMode) String() string {
+	switch rm {
+	case FixedRate:
+		return "FixedRate"
+	case FixedDelay:
+		return "FixedDelay"
+	default:
+		return fmt.Sprintf("RepeatMode(%d)", int(rm))
+	}
+}
+
+// 간격이 일정하지 않은 반복 (cron 등) 의 다음 실행 시간 계산
+type Schedule interface {
+	// prev 다음의 실행 시간, 더 없으면 zero time
+	Next(prev time.Time) time.Time
+}
+
+type Repeat struct {
+	Mode     RepeatMode
+	Interval time.Duration
+	Schedule Schedule  // not nil : Mode, Interval 대신 사용
+	MaxRun   int       // 0 : no limit
+	EndTime  time.Time // zero : no limit
+}
+
+func (rp Repeat) String() string {
+	if rp.Schedule != nil {
+		return fmt.Sprintf("Repeat[%v]", rp.Schedule)
+	}
+	return fmt.Sprintf("Repeat[%v %v]", rp.Mode, rp.Interval)
+}
+
+func NewRepeat(
+	tasktime time.Time, repeat Repeat,
+	argument interface{}, doTaskFn DoTaskFn) *Task {
+
+	ft := New(tasktime, argument, doTaskFn)
+	ft.repeat = &repeat
+	return ft
+}
+
+// from 이후 sch 의 첫 시간부터 반복 실행, 첫 시간이 없으면 ErrNoOccurrence
+func NewSchedule(
+	from time.Time, sch Schedule,
+	argument interface{}, doTaskFn DoTaskFn) (*Task, error) {
+
+	first := sch.Next(from)
+	if first.IsZero() {
+		return nil, fmt.Errorf("%v after %v: %w", sch, from, ErrNoOccurrence)
+	}
+	return NewRepeat(first, Repeat{Schedule: sch}, argument, doTaskFn), nil
+}
+
+func (ft *Task) IsRepeat() bool {
+	return ft.repeat != nil
+}
+
+func (ft *Task) GetRepeat() *Repeat {
+	return ft.repeat
+}
+
+// 지금까지 실행된 횟수, retry 로 다시 실행한 것은 세지 않는다.
+func (ft *Task) RunCount() int {
+	return ft.runCount
+}
+
+// 반복을 중지 한다. 진행중인 반복이 없었으면 false
+func (ft *Task) StopRepeat() bool {
+	if !ft.IsRepeat() || ft.repeatDone {
+		return false
+	}
+	ft.repeatDone = true
+	return true
+}
+
+// 실행이 끝난 task 의 다음 실행 시간을 정한다.
+// 더 반복할 필요가 없으면 false, queue 에 다시 넣으면 안됨.
+func (ft *Task) PrepareNextRepeat(runEnd time.Time) bool {
+	if !ft.IsRepeat() || ft.repeatDone {
+		return false
+	}
+	ft.clearJitter()
+	rp := ft.repeat
+	if rp.MaxRun > 0 && ft.runCount >= rp.MaxRun {
+		ft.repeatDone = true
+		return false
+	}
+	var next time.Time
+	switch {
+	case rp.Schedule != nil:
+		next = rp.Schedule.Next(ft.slot)
+	case rp.Interval <= 0: // can not repeat, next stays zero
+	case rp.Mode == FixedDelay:
+		next = runEnd.Add(rp.Interval)
+	default:
+		next = ft.slot.Add(rp.Interval)
+	}
+	if next.IsZero() || (!rp.EndTime.IsZero() && next.After(rp.EndTime)) {
+		ft.repeatDone = true
+		return false
+	}
+	ft.tasktime = next
+	ft.slot = next
+	if ft.coalesce {
+		ft.coalesce = false
+		if !next.After(runEnd) {
+			return ft.SkipToNextRepeat(runEnd)
+		}
+	}
+	return true
+}
+
+// 실행 하지 않고 지나간 반복 시간을 건너 뛰어 now 이후의 첫 반복 시간으로 옮긴다.
+// 더 반복할 필요가 없으면 false, queue 에 다시 넣으면 안됨.
+func (ft *Task) SkipToNextRepeat(now time.Time) bool {
+	if !ft.IsRepeat() || ft.repeatDone {
+		return false
+	}
+	ft.clearJitter()
+	if ft.tasktime.After(now) {
+		return true
+	}
+	rp := ft.repeat
+	var next time.Time
+	switch {
+	case rp.Schedule != nil:
+		next = rp.Schedule.Next(now)
+	case rp.Interval <= 0: // can not repeat, next stays zero
+	case rp.Mode == FixedDelay:
+		next = now.Add(rp.Interval)
+	default:
+		missed := now.Sub(ft.slot)/rp.Interval + 1
+		next = ft.slot.Add(missed * rp.Interval)
+	}
+	if next.IsZero() || (!rp.EndTime.IsZero() && next.After(rp.EndTime)) {
+		ft.repeatDone = true
+		return false
+	}
+	ft.tasktime = next
+	ft.slot = next
+	return true
+}
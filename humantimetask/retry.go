@@ -0,0 +1,53 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"time"
+
+	"github.com/kasworld/timedtask/retrypolicy"
+)
+
+func (ft *Task) RetryPolicy() *retrypolicy.Policy {
+	return ft.retry
+}
+
+// nil 이면 queue 의 기본값을 쓴다.
+func (ft *Task) SetRetryPolicy(p *retrypolicy.Policy) {
+	ft.retry = p
+}
+
+// 실행중 이면 몇번째 시도인지, 1 부터
+func (ft *Task) Attempt() int {
+	return ft.attempt
+}
+
+// 실패한 실행을 다시 할지 정하고, 다시 하면 tasktime 을 retry 시간으로 바꾼다.
+// 예정 시간은 바꾸지 않으므로 FixedRate, Schedule 의 다음 반복은 밀리지 않는다.
+// task 에 policy 가 없으면 defaultPolicy 를 쓴다.
+func (ft *Task) PrepareRetry(err error, now time.Time, defaultPolicy *retrypolicy.Policy) bool {
+	p := ft.retry
+	if p == nil {
+		p = defaultPolicy
+	}
+	if p == nil || !p.ShouldRetry(ft.attempt, err) {
+		return false
+	}
+	ft.jitterOffset = 0
+	ft.tasktime = now.Add(p.Delay(ft.attempt))
+	return true
+}
+
+// 성공 했거나 retry 를 포기한 task 의 시도 횟수를 지운다.
+func (ft *Task) ResetAttempt() {
+	ft.attempt = 0
+}
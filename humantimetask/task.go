@@ -13,13 +13,18 @@
 package humantimetask
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"reflect"
 	"runtime"
 	"runtime/debug"
+	"sync/atomic"
 	"time"
 
+	"github.com/kasworld/timedtask/misfire"
+	"github.com/kasworld/timedtask/retrypolicy"
+	"github.com/kasworld/timedtask/taskhandle"
 	"github.com/kasworld/timedtask/taskstat"
 )
 
@@ -27,33 +32,81 @@ import (
 
 const invalidTaskIndex = -1
 
+// queue 에 Push 할때 정해지는 process 안에서 유일한 값, 0 은 아직 없음
+type TaskID uint64
+
+var lastTaskID uint64
+
+func (id TaskID) String() string {
+	return fmt.Sprintf("#%d", uint64(id))
+}
+
 type DoTaskFn func(*Task) error
 
 type Task struct {
+	id       TaskID
 	fnName   string
 	argument interface{}
 	doTaskFn DoTaskFn  // Task do function
 	tasktime time.Time // The tasktime of the item in the queue.
+	slot     time.Time // 반복 시간을 정하는 예정 시간, jitter, retry, 미룬 시간은 들어가지 않는다.
+
+	checkArg func(interface{}) error // not nil : TypedTask 의 argument 형 검사
+
+	doTaskCtxFn DoTaskCtxFn   // not nil : doTaskFn 대신 실행
+	deadline    time.Time     // zero : no deadline
+	timeout     time.Duration // 0 : queue 의 기본값
+
+	retry   *retrypolicy.Policy // nil : queue 의 기본값
+	attempt int                 // 이번 실행이 몇번째 시도인지
+	run     *runState           // 실행중 상태
+	handle  *taskhandle.Handle  // nil : no handle
+
+	repeat     *Repeat // nil : run once
+	runCount   int
+	repeatDone bool
+	coalesce   bool // 다음 반복 시간을 정할때 지나간 시간은 건너 뛴다.
+
+	misfire misfire.Policy // Default : queue 의 기본값
+
+	jitter       Jitter
+	jitterOffset time.Duration // tasktime 에 적용된 jitter
+
+	expiry   time.Time // zero : no expiry
+	onExpire ExpireFn
+
+	label     string // "" : rate limit 은 fnName 으로
+	serialKey string // "" : 다른 task 와 겹쳐 실행 될수 있다.
+
+	priority int    // 같은 시간 이면 큰것 먼저
+	seq      uint64 // 같은 시간, priority 면 작은것 먼저, Push, Update 때 정해진다.
+
 	// The index is needed by update and is maintained by the heap.Interface methods.
 	index int // The index of the item in the heap.
 }
 
 func New(tasktime time.Time, argument interface{}, doTaskFn DoTaskFn) *Task {
-	taskfnname := runtime.FuncForPC(reflect.ValueOf(doTaskFn).Pointer()).Name()
+	taskfnname := funcName(doTaskFn)
 	ft := Task{
 		fnName:   taskfnname,
 		doTaskFn: doTaskFn,
 		tasktime: tasktime,
+		slot:     tasktime,
 		argument: argument,
 		index:    invalidTaskIndex,
+		run:      &runState{},
 	}
 	return &ft
 }
 
+func funcName(fn interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
 func (ft Task) String() string {
 	return fmt.Sprintf(
-		"HumanTimeTask[%v at %v]",
-		ft.GetTaskFnName(), ft.tasktime.Format("2006-01-02T15:04:05Z07:00"))
+		"HumanTimeTask[%v %v at %v]",
+		ft.id, ft.GetTaskFnName(), ft.tasktime.Format("2006-01-02T15:04:05Z07:00"))
 }
 
 func (ft *Task) PanicString() string {
@@ -68,6 +121,14 @@ func (ft *Task) TaskTime() time.Time {
 	return ft.tasktime
 }
 
+// queue 에 없는 task 만, queue 안의 task 는 UpdateTaskTime 을 쓴다.
+// 새 시간은 jitter 적용 전 시간이다. Push 할때 jitter 를 다시 적용한다.
+func (ft *Task) SetTaskTime(tasktime time.Time) {
+	ft.tasktime = tasktime
+	ft.slot = tasktime
+	ft.jitterOffset = 0
+}
+
 func (ft *Task) Argument() interface{} {
 	return ft.argument
 }
@@ -76,15 +137,70 @@ func (ft *Task) GetTaskFnName() string {
 	return ft.fnName
 }
 
+func (ft *Task) Priority() int {
+	return ft.priority
+}
+
+// 같은 시간 task 중 큰것이 먼저 실행 된다. Push 전에 정한다.
+func (ft *Task) SetPriority(priority int) {
+	ft.priority = priority
+}
+
+func (ft *Task) ID() TaskID {
+	return ft.id
+}
+
+// ID 가 없으면 새로 정한다. queue 의 Push 에서 부른다.
+func (ft *Task) AssignID() TaskID {
+	if ft.id == 0 {
+		ft.id = TaskID(atomic.AddUint64(&lastTaskID, 1))
+	}
+	return ft.id
+}
+
 func (ft *Task) IsValid() bool {
 	return ft.index != invalidTaskIndex
 }
+
+// 지금 상태를 복사한 queue 밖의 task, ID 는 같다. dead letter 에 보관할때 쓴다.
+// 실행 상태와 handle 은 복사하지 않는다.
+func (ft *Task) Snapshot() *Task {
+	st := *ft
+	st.index = invalidTaskIndex
+	st.run = &runState{}
+	st.handle = nil
+	if ft.repeat != nil {
+		repeat := *ft.repeat
+		st.repeat = &repeat
+	}
+	return &st
+}
 func (ft *Task) RunWithStat(ts *taskstat.StatObj) error {
-	defer RecoverPanic(ft)
-	err := ft.GetTaskFn()(ft)
+	return ft.RunWithContext(context.Background(), ts)
+}
+
+// DoTaskCtxFn 에 주는 ctx 는 parent 가 끝나거나, deadline 이나 timeout 이 지나거나,
+// CancelRun 을 부르면 취소 된다.
+func (ft *Task) RunWithContext(parent context.Context, ts *taskstat.StatObj) error {
+	return ft.RunWithTimeout(parent, ts, ft.timeout)
+}
+
+func (ft *Task) runFn(ctx context.Context, ts *taskstat.StatObj) (rtnErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ts.Panic()
+			rtnErr = newPanicError(ft, r, debug.Stack())
+		}
+	}()
+	var err error
+	if ft.doTaskCtxFn != nil {
+		err = ft.doTaskCtxFn(ctx, ft)
+	} else {
+		err = ft.GetTaskFn()(ft)
+	}
 	ts.Commit()
 	if err != nil {
-		return fmt.Errorf("%v %v", ft, err)
+		return fmt.Errorf("%v %w", ft, err)
 	} else {
 		ts.Success()
 	}
@@ -93,14 +209,18 @@ func (ft *Task) RunWithStat(ts *taskstat.StatObj) error {
 
 func RecoverPanic(obj *Task) {
 	if r := recover(); r != nil {
-		errMsg := fmt.Sprintf(
-			"RecoverPanic %v\n\n%v\n\n%s\n\n%s",
-			time.Now().UTC(),
-			obj.PanicString(),
-			r,
-			string(debug.Stack()))
-		os.Stderr.WriteString(errMsg)
-
-		// syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		writePanic(obj, r, debug.Stack())
 	}
 }
+
+func writePanic(obj *Task, r interface{}, stack []byte) {
+	errMsg := fmt.Sprintf(
+		"RecoverPanic %v\n\n%v\n\n%s\n\n%s",
+		time.Now().UTC(),
+		obj.PanicString(),
+		r,
+		string(stack))
+	os.Stderr.WriteString(errMsg)
+
+	// syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+}
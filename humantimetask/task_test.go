@@ -0,0 +1,174 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kasworld/timedtask/taskstat"
+)
+
+func TestTask_PanicString(t *testing.T) {
+	tk := New(time.Now(), "hello", func(tt *Task) error {
+		return nil
+	})
+	t.Logf("%v", tk.PanicString())
+}
+
+func TestTask_PrepareNextRepeat(t *testing.T) {
+	base := time.Date(2019, 5, 2, 0, 0, 0, 0, time.UTC)
+	tk := NewRepeat(base, Repeat{Mode: FixedRate, Interval: time.Minute, MaxRun: 3},
+		nil, func(tt *Task) error {
+			return nil
+		})
+	runEnd := base.Add(10 * time.Second)
+	for i := 1; i < 3; i++ {
+		tk.runCount++
+		if !tk.PrepareNextRepeat(runEnd) {
+			t.Fatalf("repeat stopped at %v", i)
+		}
+		if want := base.Add(time.Duration(i) * time.Minute); !tk.TaskTime().Equal(want) {
+			t.Errorf("tasktime %v, want %v", tk.TaskTime(), want)
+		}
+	}
+	tk.runCount++
+	if tk.PrepareNextRepeat(runEnd) {
+		t.Errorf("repeat not stopped after MaxRun")
+	}
+
+	tk = NewRepeat(base, Repeat{Mode: FixedDelay, Interval: time.Minute},
+		nil, func(tt *Task) error {
+			return nil
+		})
+	if !tk.PrepareNextRepeat(runEnd) {
+		t.Fatalf("repeat stopped")
+	}
+	if want := runEnd.Add(time.Minute); !tk.TaskTime().Equal(want) {
+		t.Errorf("tasktime %v, want %v", tk.TaskTime(), want)
+	}
+	if !tk.StopRepeat() || tk.PrepareNextRepeat(runEnd) {
+		t.Errorf("StopRepeat not work")
+	}
+}
+
+type everyHour struct{ until time.Time }
+
+func (s everyHour) Next(prev time.Time) time.Time {
+	next := prev.Truncate(time.Hour).Add(time.Hour)
+	if next.After(s.until) {
+		return time.Time{}
+	}
+	return next
+}
+
+func TestNewSchedule(t *testing.T) {
+	base := time.Date(2019, 5, 2, 0, 30, 0, 0, time.UTC)
+	tk, err := NewSchedule(base, everyHour{base.Add(time.Hour)}, nil, func(tt *Task) error {
+		return nil
+	})
+	if err != nil || !tk.TaskTime().Equal(base.Add(30*time.Minute)) {
+		t.Fatalf("NewSchedule %v %v", tk, err)
+	}
+	tk, err = NewSchedule(base, everyHour{base}, nil, func(tt *Task) error {
+		return nil
+	})
+	if tk != nil || !errors.Is(err, ErrNoOccurrence) {
+		t.Errorf("no occurrence %v %v", tk, err)
+	}
+}
+
+func TestTypedTask(t *testing.T) {
+	type saveArg struct {
+		playerID int
+	}
+	var got int
+	tt := NewTyped(time.Now(), saveArg{playerID: 7}, func(tk *TypedTask[saveArg]) error {
+		got = tk.Argument().playerID
+		return nil
+	})
+	if err := tt.GetTaskFn()(tt.Task); err != nil || got != 7 {
+		t.Errorf("typed run %v %v", got, err)
+	}
+	tt.argument = "wrong"
+	if err := tt.GetTaskFn()(tt.Task); err == nil {
+		t.Errorf("wrong argument type run")
+	}
+	var tl TaskList
+	tt.argument = saveArg{playerID: 7}
+	heap.Push(&tl, tt.Task)
+	if err := tl.Update(tt.Task, "wrong", tt.TaskTime(), tt.GetTaskFn()); err == nil || tt.Argument().playerID != 7 {
+		t.Errorf("wrong argument type updated")
+	}
+	t.Logf("%v", tt)
+}
+
+func TestTask_RunWithContext(t *testing.T) {
+	started := make(chan struct{})
+	tk := NewWithContext(time.Now(), nil, func(ctx context.Context, tt *Task) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	go func() {
+		<-started
+		tk.CancelRun()
+	}()
+	if err := tk.RunWithStat(taskstat.New().GetStatByFuncName(tk.GetTaskFnName())); err == nil {
+		t.Errorf("cancelled task no error")
+	}
+	if tk.IsRunning() {
+		t.Errorf("task running after end")
+	}
+
+	tk = NewWithContext(time.Now(), nil, func(ctx context.Context, tt *Task) error {
+		<-ctx.Done()
+		return nil
+	})
+	tk.SetDeadline(time.Now().Add(time.Millisecond))
+	tk.RunWithStat(taskstat.New().GetStatByFuncName(tk.GetTaskFnName()))
+}
+
+func TestTask_Jitter(t *testing.T) {
+	base := time.Date(2019, 5, 2, 0, 0, 0, 0, time.UTC)
+	window := time.Minute
+	tk := NewRepeat(base, Repeat{Mode: FixedRate, Interval: time.Hour},
+		nil, func(tt *Task) error {
+			return nil
+		})
+	tk.SetJitter(Jitter{Window: window, Key: "player-1"})
+	tk.ApplyJitter(Jitter{})
+	offset := tk.TaskTime().Sub(base)
+	if offset < 0 || offset >= window || !tk.BaseTime().Equal(base) {
+		t.Fatalf("jitter out of window %v", tk.TaskTime())
+	}
+	tk.ApplyJitter(Jitter{}) // same key, same offset
+	if got := tk.TaskTime().Sub(base); got != offset {
+		t.Errorf("key jitter not deterministic %v %v", got, offset)
+	}
+	if !tk.PrepareNextRepeat(base) || !tk.TaskTime().Equal(base.Add(time.Hour)) {
+		t.Errorf("next repeat not from base %v", tk.TaskTime())
+	}
+
+	tk = New(base, nil, func(tt *Task) error {
+		return nil
+	})
+	for i := 0; i < 100; i++ {
+		got, _ := tk.JitterTime(base, Jitter{Window: window})
+		if got.Before(base) || !got.Before(base.Add(window)) {
+			t.Fatalf("random jitter out of window %v", got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kasworld/timedtask/clock"
+	"github.com/kasworld/timedtask/taskstat"
+)
+
+var ErrTimeout = errors.New("task timeout")
+
+func (ft *Task) Timeout() time.Duration {
+	return ft.timeout
+}
+
+// 실행 시간 제한, 0 이면 queue 의 기본값을 쓴다.
+func (ft *Task) SetTimeout(timeout time.Duration) {
+	ft.timeout = timeout
+}
+
+// timeout 이 지나면 ctx 를 취소하고, 끝나기를 기다리지 않고 ErrTimeout 을 돌려준다.
+// 멈춘 DoTaskFn 은 goroutine 에 남아 있다가 끝나면 결과를 RunWaiter 로 볼수 있다.
+// 그 동안 RunDone 이 nil 이 아니고, 끝나기 전에 다시 실행 하면 안된다.
+// timeout <= 0 이면 끝날때 까지 기다린다.
+func (ft *Task) RunWithTimeout(parent context.Context, ts *taskstat.StatObj, timeout time.Duration) error {
+	return ft.RunWithClock(parent, ts, timeout, clock.Real{})
+}
+
+// RunWithTimeout 과 같으나 timeout 과 deadline 을 clk 의 시간으로 잰다.
+func (ft *Task) RunWithClock(parent context.Context, ts *taskstat.StatObj, timeout time.Duration, clk clock.Clock) error {
+	if ft.attempt == 0 { // retry 는 반복 횟수로 세지 않는다.
+		ft.runCount++
+	}
+	ft.attempt++
+	ctx, runEnd := ft.runContext(parent, clk)
+	if timeout <= 0 {
+		err := ft.runFn(ctx, ts)
+		runEnd(err)
+		return err
+	}
+	ctx, cancel := clock.WithTimeout(ctx, clk, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		err := ft.runFn(ctx, ts)
+		runEnd(err)
+		done <- err
+	}()
+
+	timer := clk.NewTimer(timeout)
+	clock.Ack(timer) // Fake 의 Advance 는 timeout 을 알리고 Stop 할 때 까지 기다린다.
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C():
+		if !ts.Timeout() { // ended just now
+			return <-done
+		}
+		return fmt.Errorf("%v overrun %v: %w", ft, timeout, ErrTimeout)
+	}
+}
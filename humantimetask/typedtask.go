@@ -0,0 +1,75 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetask
+
+import (
+	"fmt"
+	"time"
+)
+
+// argument 형을 compile 때 검사하는 task
+// queue 에는 embed 된 Task 를 넣는다. q.Push(tt.Task)
+
+type TypedDoTaskFn[A any] func(*TypedTask[A]) error
+
+type TypedTask[A any] struct {
+	*Task
+}
+
+func NewTyped[A any](tasktime time.Time, argument A, doTaskFn TypedDoTaskFn[A]) *TypedTask[A] {
+	tt := &TypedTask[A]{}
+	tt.Task = New(tasktime, argument, typedDoTaskFn(doTaskFn))
+	tt.fnName = funcName(doTaskFn)
+	tt.checkArg = checkArgument[A]
+	return tt
+}
+
+func NewTypedRepeat[A any](
+	tasktime time.Time, repeat Repeat,
+	argument A, doTaskFn TypedDoTaskFn[A]) *TypedTask[A] {
+
+	tt := NewTyped(tasktime, argument, doTaskFn)
+	tt.repeat = &repeat
+	return tt
+}
+
+// UpdateTaskArgAndTime 은 다른 형의 argument 를 받지 않으므로 nil argument 일때만 zero
+func (tt *TypedTask[A]) Argument() A {
+	arg, _ := tt.argument.(A)
+	return arg
+}
+
+// 다른 형의 argument 면 실행하지 않고 error
+func typedDoTaskFn[A any](doTaskFn TypedDoTaskFn[A]) DoTaskFn {
+	return func(ft *Task) error {
+		if err := checkArgument[A](ft.argument); err != nil {
+			return err
+		}
+		return doTaskFn(&TypedTask[A]{ft})
+	}
+}
+
+func checkArgument[A any](argument interface{}) error {
+	if _, ok := argument.(A); !ok && argument != nil {
+		var zero A
+		return fmt.Errorf("argument type %T, want %T", argument, zero)
+	}
+	return nil
+}
+
+// TypedTask 면 argument 형을 검사한다.
+func (ft *Task) checkArgument(argument interface{}) error {
+	if ft.checkArg == nil {
+		return nil
+	}
+	return ft.checkArg(argument)
+}
@@ -0,0 +1,61 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetaskqueue
+
+import (
+	"github.com/kasworld/timedtask/humantimetask"
+)
+
+// rate limit 이나 serial key 의 자리를 넘겨 받아 Run 이 실행할 task
+type handoff struct {
+	t      *humantimetask.Task
+	serial bool // serial key 자리를 넘겨 받았다. holdForSerialKey 를 다시 거치지 않는다.
+}
+
+// 자리를 넘겨 받은 task 를 Run 이 실행하도록 넣는다. mutex 안에서 부른다.
+// 끝난 task 를 실행한 goroutine 은 executor 의 worker 나 game loop 일수 있어
+// 거기서 executor 로 보내면 자기가 받기를 기다리며 멈춘다.
+func (tq *TaskQueue) handOff(t *humantimetask.Task, serial bool) {
+	tq.handoffs = append(tq.handoffs, handoff{t: t, serial: serial})
+	select {
+	case tq.wakeHandoff <- struct{}{}:
+	default:
+	}
+}
+
+// 넘겨 받은 task 를 serial key 를 거쳐 run 으로 실행한다. 실행 하거나 정리한 수를 돌려준다.
+// 기다리는 동안 Remove 된 task 는 실행하지 않고 자리를 돌려준다.
+func (tq *TaskQueue) startHandoffs(run func(t *humantimetask.Task)) int {
+	tq.mutex.Lock()
+	handoffs := tq.handoffs
+	tq.handoffs = nil
+	tq.mutex.Unlock()
+	for _, h := range handoffs {
+		t := h.t
+		tq.mutex.RLock()
+		removed := tq.taskByID[t.ID()] != t
+		tq.mutex.RUnlock()
+		if removed {
+			tq.taskEnded(t, nil, nil) // removed 로 끝낸다.
+			tq.limitEnded(t)
+			if h.serial {
+				tq.serialEnded(t)
+			}
+			continue
+		}
+		if !h.serial && tq.holdForSerialKey(t) {
+			continue
+		}
+		run(t)
+	}
+	return len(handoffs)
+}
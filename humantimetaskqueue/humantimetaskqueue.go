@@ -14,15 +14,26 @@ package humantimetaskqueue
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/kasworld/actpersec"
+	"github.com/kasworld/timedtask/clock"
+	"github.com/kasworld/timedtask/deadletter"
+	"github.com/kasworld/timedtask/executor"
 	"github.com/kasworld/timedtask/humantimetask"
 	"github.com/kasworld/timedtask/humantimetaskqueuei"
 	"github.com/kasworld/timedtask/loggeri"
+	"github.com/kasworld/timedtask/misfire"
+	"github.com/kasworld/timedtask/ratelimit"
+	"github.com/kasworld/timedtask/retrypolicy"
+	"github.com/kasworld/timedtask/serialkey"
+	"github.com/kasworld/timedtask/taskdep"
+	"github.com/kasworld/timedtask/taskhandle"
 	"github.com/kasworld/timedtask/taskstat"
+	"github.com/kasworld/timedtask/workerpool"
 )
 
 var _ humantimetaskqueuei.TaskQueueI = &TaskQueue{}
@@ -33,23 +44,53 @@ type TaskQueue struct {
 
 	runTasksEndWaitGroup sync.WaitGroup // 실행중인 task가 모두 끝났음을 보장
 	paused               bool
+	flushing             bool      // FlushTaskTill 중, flushTime 을 지금으로 본다.
+	flushTime            time.Time // FlushTaskTill 이 실행 하는 task 의 지금 시간
 	runStat              *actpersec.ActPerSec
+	runCtx               context.Context // Run 의 ctx, task 실행에 쓴다.
 	pQueue               humantimetask.TaskList
+	taskByID             map[humantimetask.TaskID]*humantimetask.Task // pushed, not ended
+	taskDep              *taskdep.Graph[humantimetask.TaskID]
+	rateLimit            *ratelimit.Limiter[*humantimetask.Task]
+	serialKey            *serialkey.Lanes[*humantimetask.Task]
+	handoffs             []handoff // rate limit, serial key 자리를 넘겨 받아 Run 이 실행할 task
+	wakeHandoff          chan struct{}
+	executor             executor.Executor // nil : executor.Goroutine
+	workerPool           *workerpool.Pool  // SetWorkerPool 로 만든 pool
 	Name                 string
 	repeatWait           time.Duration
 	popDelay             time.Duration
+	clock                clock.Clock
+	defaultTimeout       time.Duration              // 0 : no timeout
+	retryPolicy          *retrypolicy.Policy        // nil : no retry
+	panicHandler         humantimetask.PanicHandler // nil : humantimetask.StderrPanic
+	misfirePolicy        misfire.Policy             // Default : misfire.RunNow
+	jitter               humantimetask.Jitter       // Window 0 : no jitter
 	taskStat             *taskstat.TaskStat
+	deadLetter           *deadletter.Store[*humantimetask.Task]
 }
 
 func New(name string, popDelay time.Duration, repeatWait time.Duration, l loggeri.LoggerI) *TaskQueue {
+	return NewWithClock(name, popDelay, repeatWait, l, clock.Real{})
+}
+
+// clk 으로 시간을 재고 기다린다. test 에서는 clock.Fake
+func NewWithClock(name string, popDelay time.Duration, repeatWait time.Duration, l loggeri.LoggerI, clk clock.Clock) *TaskQueue {
 	tq := &TaskQueue{
-		log:        l,
-		pQueue:     make(humantimetask.TaskList, 0),
-		Name:       name,
-		popDelay:   popDelay,
-		repeatWait: repeatWait,
-		taskStat:   taskstat.New(),
-		runStat:    actpersec.New(),
+		log:         l,
+		pQueue:      make(humantimetask.TaskList, 0),
+		taskByID:    make(map[humantimetask.TaskID]*humantimetask.Task),
+		taskDep:     taskdep.New[humantimetask.TaskID](),
+		rateLimit:   ratelimit.New[*humantimetask.Task](),
+		serialKey:   serialkey.New[*humantimetask.Task](),
+		wakeHandoff: make(chan struct{}, 1),
+		Name:        name,
+		popDelay:    popDelay,
+		repeatWait:  repeatWait,
+		clock:       clk,
+		taskStat:    taskstat.New(),
+		deadLetter:  deadletter.New[*humantimetask.Task](deadLetterMaxLen),
+		runStat:     actpersec.New(),
 	}
 	return tq
 }
@@ -57,12 +98,12 @@ func New(name string, popDelay time.Duration, repeatWait time.Duration, l logger
 func (tq TaskQueue) String() string {
 	if tq.paused {
 		return fmt.Sprintf(
-			"HumanTimeTaskQueue[%v paused %v %v]",
-			tq.Name, tq.Len(), tq.runStat)
+			"HumanTimeTaskQueue[%v paused %v %v%s]",
+			tq.Name, tq.Len(), tq.runStat, tq.workerPoolString())
 	} else {
 		return fmt.Sprintf(
-			"HumanTimeTaskQueue[%v running %v %v]",
-			tq.Name, tq.Len(), tq.runStat)
+			"HumanTimeTaskQueue[%v running %v %v%s]",
+			tq.Name, tq.Len(), tq.runStat, tq.workerPoolString())
 	}
 }
 
@@ -96,7 +137,12 @@ func (tq *TaskQueue) UpdateTaskArgAndTime(t *humantimetask.Task, uparg interface
 	}
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
-	return tq.pQueue.Update(t, uparg, uptick, t.GetTaskFn())
+	jittered, offset := t.JitterTime(uptick, tq.jitter)
+	if err := tq.pQueue.Update(t, uparg, jittered, t.GetTaskFn()); err != nil {
+		return err // jitter 는 바꾸지 않는다.
+	}
+	t.SetJitterOffset(offset)
+	return nil
 }
 
 func (tq *TaskQueue) UpdateTaskTime(t *humantimetask.Task, uptime time.Time) error {
@@ -105,7 +151,12 @@ func (tq *TaskQueue) UpdateTaskTime(t *humantimetask.Task, uptime time.Time) err
 	}
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
-	return tq.pQueue.Update(t, t.Argument(), uptime, t.GetTaskFn())
+	jittered, offset := t.JitterTime(uptime, tq.jitter)
+	if err := tq.pQueue.Update(t, t.Argument(), jittered, t.GetTaskFn()); err != nil {
+		return err // jitter 는 바꾸지 않는다.
+	}
+	t.SetJitterOffset(offset)
+	return nil
 }
 
 func (tq *TaskQueue) Remove(t *humantimetask.Task) error {
@@ -115,7 +166,44 @@ func (tq *TaskQueue) Remove(t *humantimetask.Task) error {
 
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
-	return tq.pQueue.Remove(t)
+	if tq.removeWaiting(t) {
+		return nil
+	}
+	if !t.IsValid() && tq.taskByID[t.ID()] == t {
+		// running task, cancel ctx. taskEnded 에서 retry, repeat 하지 않고 끝낸다.
+		delete(tq.taskByID, t.ID())
+		t.CancelRun()
+		return nil
+	}
+	if err := tq.pQueue.Remove(t); err != nil {
+		return err
+	}
+	delete(tq.taskByID, t.ID())
+	t.Handle().Cancel()
+	tq.prerequisiteEnded(t, false)
+	return nil
+}
+
+func (tq *TaskQueue) GetByID(id humantimetask.TaskID) *humantimetask.Task {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	return tq.taskByID[id]
+}
+
+func (tq *TaskQueue) RemoveByID(id humantimetask.TaskID) error {
+	t := tq.GetByID(id)
+	if t == nil {
+		return fmt.Errorf("%v remove failed, not found task %v", tq, id)
+	}
+	return tq.Remove(t)
+}
+
+func (tq *TaskQueue) RescheduleByID(id humantimetask.TaskID, uptime time.Time) error {
+	t := tq.GetByID(id)
+	if t == nil {
+		return fmt.Errorf("%v reschedule failed, not found task %v", tq, id)
+	}
+	return tq.UpdateTaskTime(t, uptime)
 }
 
 func (tq *TaskQueue) Peek() *humantimetask.Task {
@@ -129,16 +217,32 @@ func (tq *TaskQueue) Peek() *humantimetask.Task {
 	return nil
 }
 
+// 꺼낸 task 는 queue 가 더 관리하지 않는다. GetByID 로 찾을수 없다.
+// 붙은 handle 은 Cancelled 로 끝난다.
 func (tq *TaskQueue) Pop() *humantimetask.Task {
 	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	t := tq.pop()
+	if t != nil {
+		delete(tq.taskByID, t.ID())
+		t.Handle().Cancel()
+	}
+	return t
+}
+
+// 실행 하려고 꺼낸다. 끝날때 까지 taskByID 에 남는다.
+func (tq *TaskQueue) popToRun() *humantimetask.Task {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	return tq.pop()
+}
+
+// mutex 안에서 부른다.
+func (tq *TaskQueue) pop() *humantimetask.Task {
 	if len(tq.pQueue) == 0 {
-		tq.mutex.Unlock()
 		return nil
 	}
-	t := tq.pQueue[0]
-	_ = heap.Pop(&tq.pQueue)
-	tq.mutex.Unlock()
-	return t
+	return heap.Pop(&tq.pQueue).(*humantimetask.Task)
 }
 
 func (tq *TaskQueue) Push(t *humantimetask.Task) {
@@ -148,12 +252,64 @@ func (tq *TaskQueue) Push(t *humantimetask.Task) {
 
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
+	tq.push(t)
+}
+
+// mutex 안에서 부른다.
+func (tq *TaskQueue) push(t *humantimetask.Task) {
 	if t.IsValid() {
 		tq.log.Fatal("%v tried to push %v already pushed", tq, t)
 	}
+	if t.TaskTime().IsZero() {
+		tq.log.Fatal("%v tried to push %v without time", tq, t)
+	}
+	t.ApplyJitter(tq.jitter)
 	heap.Push(&tq.pQueue, t)
+	tq.taskByID[t.AssignID()] = t
+}
+
+// 끝나기를 기다리고 결과를 받을 handle 을 붙여서 넣는다.
+func (tq *TaskQueue) PushWithHandle(t *humantimetask.Task) *taskhandle.Handle {
+	h := t.AttachHandle()
+	tq.Push(t)
+	return h
 }
 
 func (tq *TaskQueue) Len() int {
 	return tq.pQueue.Len()
 }
+
+// timeout 이 없는 task 의 실행 시간 제한, 넘으면 ctx 를 취소하고 기다리지 않는다.
+func (tq *TaskQueue) SetDefaultTimeout(timeout time.Duration) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.defaultTimeout = timeout
+}
+
+// 실패한 task 를 다시 실행하는 기본 policy, task 의 policy 가 우선 한다. nil 이면 다시 하지 않는다.
+func (tq *TaskQueue) SetRetryPolicy(p *retrypolicy.Policy) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.retryPolicy = p
+}
+
+// task 가 panic 하면 부른다. humantimetask.LogPanic, RePanic, TerminatePanic 이나 직접 만든 handler
+func (tq *TaskQueue) SetPanicHandler(h humantimetask.PanicHandler) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.panicHandler = h
+}
+
+// popDelay 보다 늦게 꺼낸 task 의 기본 처리, task 의 policy 가 우선 한다.
+func (tq *TaskQueue) SetMisfirePolicy(p misfire.Policy) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.misfirePolicy = p
+}
+
+// jitter 가 없는 task 에 Push, reschedule 할때 적용한다.
+func (tq *TaskQueue) SetJitter(jt humantimetask.Jitter) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.jitter = jt
+}
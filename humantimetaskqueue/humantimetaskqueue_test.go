@@ -12,8 +12,696 @@
 package humantimetaskqueue
 
 import (
+	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/kasworld/timedtask/clock"
+	"github.com/kasworld/timedtask/executor"
+	"github.com/kasworld/timedtask/humantimetask"
+	"github.com/kasworld/timedtask/misfire"
+	"github.com/kasworld/timedtask/ratelimit"
+	"github.com/kasworld/timedtask/retrypolicy"
+	"github.com/kasworld/timedtask/simulation"
+	"github.com/kasworld/timedtask/taskdep"
+	"github.com/kasworld/timedtask/taskhandle"
 )
 
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Fatal(format string, v ...interface{})        { l.t.Fatalf(format, v...) }
+func (l testLogger) Error(format string, v ...interface{})        { l.t.Logf(format, v...) }
+func (l testLogger) Warn(format string, v ...interface{})         { l.t.Logf(format, v...) }
+func (l testLogger) Debug(format string, v ...interface{})        {}
+func (l testLogger) TraceService(format string, v ...interface{}) {}
+
 func TestNew(t *testing.T) {
 }
+
+func TestTaskQueue_Repeat(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	base := time.Now()
+	rateRun, delayRun := 0, 0
+	rate := humantimetask.NewRepeat(base,
+		humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: time.Minute},
+		nil, func(tt *humantimetask.Task) error {
+			rateRun++
+			return nil
+		})
+	delay := humantimetask.NewRepeat(base,
+		humantimetask.Repeat{Mode: humantimetask.FixedDelay, Interval: time.Minute},
+		nil, func(tt *humantimetask.Task) error {
+			delayRun++
+			return nil
+		})
+	tq.Push(rate)
+	tq.Push(delay)
+	tq.FlushTaskTill(base.Add(2*time.Minute + time.Second))
+	if rateRun != 3 || delayRun != 3 {
+		t.Errorf("run FixedRate %v, want 3, FixedDelay %v, want 3", rateRun, delayRun)
+	}
+	if tq.Len() != 2 || !rate.IsValid() || !delay.IsValid() {
+		t.Errorf("repeat task not in queue %v", tq.Len())
+	}
+}
+
+func TestTaskQueue_FlushAfterRun(t *testing.T) {
+	tq := New("test", time.Second, 10*time.Millisecond, testLogger{t})
+	ctx, cancel := context.WithCancel(context.Background())
+	runEnd := make(chan struct{})
+	go func() {
+		tq.Run(ctx)
+		close(runEnd)
+	}()
+	cancel()
+	<-runEnd
+
+	h := tq.PushWithHandle(humantimetask.NewWithContext(time.Now(), nil,
+		func(ctx context.Context, tt *humantimetask.Task) error {
+			return ctx.Err()
+		}))
+	tq.FlushTaskTill(time.Now())
+	if err := h.Err(); err != nil || h.Status() != taskhandle.Succeeded {
+		t.Errorf("flush after Run %v %v", err, h)
+	}
+}
+
+func TestTaskQueue_Timeout(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetDefaultTimeout(10 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	tk := humantimetask.NewWithContext(time.Now(), nil, func(ctx context.Context, tt *humantimetask.Task) error {
+		<-block // ignore ctx, stuck
+		return nil
+	})
+	tq.Push(tk)
+	tq.FlushTaskTill(time.Now())
+	st := tq.GetTaskStat().GetStat(tk.GetTaskFnName())
+	if st == nil || st.TimeoutCount != 1 || st.EndCount != 1 || st.SuccessCount != 0 {
+		t.Errorf("timeout stat %+v", st)
+	}
+}
+
+func TestTaskQueue_TimeoutRetry(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetDefaultTimeout(10 * time.Millisecond)
+	tq.SetRetryPolicy(&retrypolicy.Policy{MaxAttempts: 3})
+	block := make(chan struct{})
+	tk := humantimetask.NewWithContext(time.Now(), nil, func(ctx context.Context, tt *humantimetask.Task) error {
+		<-block // ignore ctx, stuck
+		return ctx.Err()
+	})
+	tq.Push(tk)
+	tq.FlushTaskTill(time.Now())
+	if tq.Peek() != nil || !tk.IsRunning() {
+		t.Errorf("requeued before stuck run end %v", tk.IsRunning())
+	}
+	close(block)
+	<-tk.RunDone()
+	for i := 0; i < 100 && tq.Peek() == nil; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if tq.Peek() != tk || tk.Attempt() != 1 {
+		t.Errorf("not retried after stuck run end %v", tk.Attempt())
+	}
+}
+
+func TestTaskQueue_LatePanic(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetDefaultTimeout(10 * time.Millisecond)
+	handled := make(chan interface{}, 1)
+	tq.SetPanicHandler(func(tt *humantimetask.Task, recovered interface{}, stack []byte) {
+		handled <- recovered
+	})
+	block := make(chan struct{})
+	tk := humantimetask.New(time.Now(), nil, func(tt *humantimetask.Task) error {
+		<-block
+		panic("late")
+	})
+	tq.Push(tk)
+	tq.FlushTaskTill(time.Now())
+	close(block)
+	select {
+	case r := <-handled:
+		if r != "late" {
+			t.Errorf("handled %v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("late panic not handled")
+	}
+	dl := tq.ListDeadLetter()
+	if len(dl) != 1 || !dl[0].IsPanic() {
+		t.Errorf("dead letter %v", dl)
+	}
+	st := tq.GetTaskStat().GetStat(tk.GetTaskFnName())
+	if st == nil || st.PanicCount != 1 || st.TimeoutCount != 1 || st.EndCount != 1 {
+		t.Errorf("late panic stat %+v", st)
+	}
+}
+
+func TestTaskQueue_Retry(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetRetryPolicy(&retrypolicy.Policy{MaxAttempts: 3})
+	var attempts []int
+	tk := humantimetask.New(time.Now(), nil, func(tt *humantimetask.Task) error {
+		attempts = append(attempts, tt.Attempt())
+		return errors.New("fail")
+	})
+	tq.Push(tk)
+	tq.FlushTaskTill(time.Now().Add(time.Second))
+	if len(attempts) != 3 || attempts[2] != 3 {
+		t.Errorf("attempts %v", attempts)
+	}
+	if tq.Len() != 0 || tk.Attempt() != 0 {
+		t.Errorf("retry not ended %v %v", tq.Len(), tk.Attempt())
+	}
+	st := tq.GetTaskStat().GetStat(tk.GetTaskFnName())
+	if st == nil || st.RetryCount != 2 || st.EndCount != 3 {
+		t.Errorf("retry stat %+v", st)
+	}
+}
+
+func TestTaskQueue_RetryMaxRun(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetRetryPolicy(&retrypolicy.Policy{MaxAttempts: 2})
+	base := time.Now()
+	calls, ok := 0, 0
+	rp := humantimetask.NewRepeat(base,
+		humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: time.Minute, MaxRun: 3},
+		nil, func(tt *humantimetask.Task) error {
+			calls++
+			if tt.Attempt() == 1 && ok < 2 { // 처음 두번은 retry 로 성공한다.
+				return errors.New("fail")
+			}
+			ok++
+			return nil
+		})
+	tq.Push(rp)
+	tq.FlushTaskTill(base.Add(10 * time.Minute))
+	if ok != 3 || calls != 5 || rp.RunCount() != 3 {
+		t.Errorf("ok %v calls %v run count %v, want 3 5 3", ok, calls, rp.RunCount())
+	}
+	if tq.Len() != 0 {
+		t.Errorf("repeat not ended %v", tq.Len())
+	}
+}
+
+func TestTaskQueue_RetryFixedRate(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tq := NewWithClock("test", time.Second, time.Second, testLogger{t}, clock.NewFake(base))
+	tq.SetRetryPolicy(&retrypolicy.Policy{MaxAttempts: 2, BaseDelay: 7 * time.Second})
+	var ran []time.Duration
+	rp := humantimetask.NewRepeat(base,
+		humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: time.Minute},
+		nil, func(tt *humantimetask.Task) error {
+			ran = append(ran, tt.TaskTime().Sub(base))
+			if len(ran) == 1 {
+				return errors.New("fail")
+			}
+			return nil
+		})
+	tq.Push(rp)
+	tq.FlushTaskTill(base.Add(time.Minute + time.Second))
+	if len(ran) != 3 || ran[1] != 7*time.Second || ran[2] != time.Minute {
+		t.Errorf("ran %v, want [0s 7s 1m0s]", ran)
+	}
+	if !rp.TaskTime().Equal(base.Add(2 * time.Minute)) {
+		t.Errorf("next %v, want 2m", rp.TaskTime().Sub(base))
+	}
+}
+
+func TestTaskQueue_RemoveRunning(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetRetryPolicy(&retrypolicy.Policy{MaxAttempts: 3})
+	started := make(chan struct{}, 3)
+	tk := humantimetask.NewWithContext(time.Now(), nil, func(ctx context.Context, tt *humantimetask.Task) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h := tq.PushWithHandle(tk)
+	tq.processTasks()
+	<-started
+	if err := tq.Remove(tk); err != nil {
+		t.Fatalf("%v", err)
+	}
+	<-h.Done()
+	tq.runTasksEndWaitGroup.Wait()
+	if h.Status() != taskhandle.Cancelled || len(started) != 0 {
+		t.Errorf("removed task %v, run again %v", h, len(started))
+	}
+	if tq.Peek() != nil || tq.GetByID(tk.ID()) != nil || tk.Attempt() != 0 {
+		t.Errorf("removed task requeued %v", tk)
+	}
+}
+
+func TestTaskQueue_UpdateRejectedJitter(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetJitter(humantimetask.Jitter{Window: time.Hour})
+	base := time.Now().Add(time.Hour)
+	tt := humantimetask.NewTyped(base, 1, func(tt *humantimetask.TypedTask[int]) error {
+		return nil
+	})
+	tq.Push(tt.Task)
+	if !tt.BaseTime().Equal(base) {
+		t.Fatalf("base %v", tt.BaseTime())
+	}
+	taskTime := tt.TaskTime()
+	for i := 0; i < 10; i++ {
+		if err := tq.UpdateTaskArgAndTime(tt.Task, "wrong", base.Add(time.Minute)); err == nil {
+			t.Fatalf("wrong argument type accepted")
+		}
+	}
+	if !tt.BaseTime().Equal(base) || !tt.TaskTime().Equal(taskTime) {
+		t.Errorf("rejected update moved base %v", tt.BaseTime().Sub(base))
+	}
+	tq.Pop()
+	if err := tq.UpdateTaskTime(tt.Task, base.Add(time.Minute)); err == nil || !tt.BaseTime().Equal(base) {
+		t.Errorf("update of popped task %v, base moved %v", err, tt.BaseTime().Sub(base))
+	}
+}
+
+func TestTaskQueue_RequeueJitter(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetJitter(humantimetask.Jitter{Window: time.Hour, Key: "player-1"})
+	base := time.Now().Add(time.Hour)
+	tk := humantimetask.New(base, nil, func(tt *humantimetask.Task) error {
+		return errors.New("fail")
+	})
+	tq.Push(tk)
+	offset := tk.TaskTime().Sub(base)
+	if offset <= 0 {
+		t.Fatalf("no jitter %v", offset)
+	}
+	tq.FlushTaskTill(base.Add(time.Hour))
+	dl := tq.ListDeadLetter()
+	if len(dl) != 1 {
+		t.Fatalf("dead letter %v", dl)
+	}
+	requeueAt := base.Add(24 * time.Hour)
+	if err := tq.RequeueDeadLetter(dl[0].ID, requeueAt); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got := tq.GetByID(tk.ID()).TaskTime().Sub(requeueAt); got != offset {
+		t.Errorf("requeue jitter %v, want %v", got, offset)
+	}
+}
+
+func TestTaskQueue_DeadLetterRepeat(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	base := time.Now().Add(time.Hour)
+	rp := humantimetask.NewRepeat(base,
+		humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: time.Minute, MaxRun: 3},
+		"arg", func(tt *humantimetask.Task) error {
+			return errors.New("fail")
+		})
+	tq.Push(rp)
+	tq.FlushTaskTill(base)
+	dl := tq.ListDeadLetter()
+	if len(dl) != 1 {
+		t.Fatalf("dead letter %v", dl)
+	}
+	if dl[0].Task == rp || !dl[0].Task.TaskTime().Equal(base) || dl[0].Task.IsValid() {
+		t.Errorf("dead letter not snapshot %v", dl[0].Task)
+	}
+	if err := tq.RequeueDeadLetter(dl[0].ID, base); err == nil {
+		t.Errorf("requeued while repeat in queue")
+	}
+	tq.FlushTaskTill(base.Add(time.Minute))
+	if !dl[0].Task.TaskTime().Equal(base) || tq.Len() != 1 {
+		t.Errorf("snapshot changed %v, len %v", dl[0].Task, tq.Len())
+	}
+	if _, exist := tq.GetDeadLetter(dl[0].ID); !exist {
+		t.Errorf("refused requeue took dead letter")
+	}
+}
+
+func TestTaskQueue_PanicHandler(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	var got interface{}
+	tq.SetPanicHandler(func(tt *humantimetask.Task, recovered interface{}, stack []byte) {
+		if len(stack) == 0 {
+			t.Errorf("no stack")
+		}
+		got = recovered
+	})
+	tk := humantimetask.New(time.Now(), nil, func(tt *humantimetask.Task) error {
+		panic("boom")
+	})
+	tq.Push(tk)
+	tq.FlushTaskTill(time.Now())
+	if got != "boom" {
+		t.Errorf("handler not called %v", got)
+	}
+	st := tq.GetTaskStat().GetStat(tk.GetTaskFnName())
+	if st == nil || st.PanicCount != 1 || st.EndCount != 1 || st.SuccessCount != 0 {
+		t.Errorf("panic stat %+v", st)
+	}
+
+	tq.SetPanicHandler(humantimetask.RePanic)
+	tk.SetSerialKey("player-1")
+	tq.Push(tk)
+	defer func() {
+		r := recover()
+		if pe, ok := r.(*humantimetask.PanicError); !ok || pe.Recovered != "boom" {
+			t.Errorf("not repanic %v", r)
+		}
+		if tq.GetByID(tk.ID()) != nil || tq.serialKey.Len() != 0 {
+			t.Errorf("not ended before repanic %v", tq.serialKey.Len())
+		}
+	}()
+	tq.FlushTaskTill(time.Now())
+	t.Errorf("not repanic")
+}
+
+func TestTaskQueue_Misfire(t *testing.T) {
+	tq := New("test", time.Millisecond, time.Second, testLogger{t})
+	tq.SetMisfirePolicy(misfire.Skip)
+	now := time.Now()
+	ran := 0
+	fn := func(tt *humantimetask.Task) error {
+		ran++
+		return nil
+	}
+	stale := humantimetask.New(now.Add(-time.Hour), nil, fn)
+	tq.Push(stale)
+	rp := humantimetask.NewRepeat(now.Add(-time.Hour),
+		humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: time.Minute}, nil, fn)
+	rp.SetMisfirePolicy(misfire.Coalesce)
+	tq.Push(rp)
+	tq.processTasks()
+	tq.runTasksEndWaitGroup.Wait()
+	if ran != 1 || tq.GetByID(stale.ID()) != nil || tq.Len() != 1 {
+		t.Errorf("ran %v, len %v", ran, tq.Len())
+	}
+	if !rp.TaskTime().After(now) || rp.TaskTime().After(now.Add(time.Minute)) {
+		t.Errorf("not coalesced %v", rp.TaskTime())
+	}
+	st := tq.GetTaskStat().GetStat(stale.GetTaskFnName())
+	if st == nil || st.MisfireCount != 2 {
+		t.Errorf("misfire stat %+v", st)
+	}
+}
+
+func TestTaskQueue_FlushMisfire(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetMisfirePolicy(misfire.Skip)
+	now := time.Now()
+	ran := 0
+	fn := func(tt *humantimetask.Task) error {
+		ran++
+		return nil
+	}
+	tq.Push(humantimetask.New(now.Add(-time.Hour), nil, fn))
+	expired := humantimetask.New(now.Add(time.Minute), nil, fn)
+	expired.SetExpiry(now.Add(-time.Second), nil)
+	tq.Push(expired)
+	tq.Push(humantimetask.New(now.Add(time.Hour), nil, fn))
+	tq.FlushTaskTill(now.Add(time.Hour))
+	if ran != 1 || tq.Len() != 0 {
+		t.Errorf("ran %v, want 1, len %v", ran, tq.Len())
+	}
+	st := tq.GetTaskStat().GetStat(expired.GetTaskFnName())
+	if st == nil || st.MisfireCount != 1 || st.ExpireCount != 1 {
+		t.Errorf("flush stat %+v", st)
+	}
+}
+
+func TestTaskQueue_PrerequisiteMisfire(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(base)
+	tq := NewWithClock("test", time.Second, time.Second, testLogger{t}, clk)
+	tq.SetMisfirePolicy(misfire.Skip)
+	first := humantimetask.New(base, nil, func(tt *humantimetask.Task) error {
+		clk.Set(base.Add(time.Hour)) // long run
+		return nil
+	})
+	tq.Push(first)
+	ran := false
+	after := humantimetask.New(base, nil, func(tt *humantimetask.Task) error {
+		ran = true
+		return nil
+	})
+	if err := tq.PushAfter(after, taskdep.FailCancel, first); err != nil {
+		t.Fatalf("%v", err)
+	}
+	tq.processTasks()
+	tq.runTasksEndWaitGroup.Wait()
+	tq.processTasks()
+	tq.runTasksEndWaitGroup.Wait()
+	if !ran {
+		t.Errorf("released dependent skipped as misfire")
+	}
+	if st := tq.GetTaskStat().GetStat(after.GetTaskFnName()); st == nil || st.MisfireCount != 0 {
+		t.Errorf("misfire stat %+v", st)
+	}
+}
+
+func TestTaskQueue_RateLimit(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetRateLimit("db", ratelimit.Limit{MaxConcurrent: 1})
+	now := time.Now()
+	block := make(chan struct{})
+	var ran int32
+	fn := func(tt *humantimetask.Task) error {
+		<-block
+		atomic.AddInt32(&ran, 1)
+		return nil
+	}
+	ts := make([]*humantimetask.Task, 3)
+	for i := range ts {
+		ts[i] = humantimetask.New(now, i, fn)
+		ts[i].SetLabel("db")
+		tq.Push(ts[i])
+	}
+	tq.processTasks()
+	if tq.Len() != 0 {
+		t.Errorf("not deferred, len %v", tq.Len())
+	}
+	if err := tq.Remove(ts[2]); err != nil {
+		t.Errorf("remove deferred %v", err)
+	}
+	close(block)
+	for i := 0; i < 3; i++ {
+		tq.runTasksEndWaitGroup.Wait()
+		tq.processTasks()
+	}
+	tq.runTasksEndWaitGroup.Wait()
+	if atomic.LoadInt32(&ran) != 2 || tq.Len() != 0 {
+		t.Errorf("ran %v, len %v", ran, tq.Len())
+	}
+	st := tq.GetTaskStat().GetStat(ts[0].GetTaskFnName())
+	if st == nil || st.DeferCount != 2 {
+		t.Errorf("defer stat %+v", st)
+	}
+}
+
+func TestTaskQueue_RateLimitFixedRate(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tq := NewWithClock("test", time.Minute, time.Second, testLogger{t}, clock.NewFake(base))
+	tq.SetRateLimit("db", ratelimit.Limit{MaxPerSecond: 0.1})
+	var ran []time.Duration
+	fn := func(tt *humantimetask.Task) error {
+		ran = append(ran, tt.TaskTime().Sub(base))
+		return nil
+	}
+	ts := make([]*humantimetask.Task, 2)
+	for i := range ts {
+		ts[i] = humantimetask.NewRepeat(base,
+			humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: time.Minute}, i, fn)
+		ts[i].SetLabel("db")
+		tq.Push(ts[i])
+	}
+	tq.FlushTaskTill(base.Add(time.Minute + 30*time.Second))
+	if len(ran) != 4 || ran[1] != 10*time.Second || ran[3] != time.Minute+10*time.Second {
+		t.Errorf("ran %v", ran)
+	}
+	for i, tk := range ts {
+		if !tk.TaskTime().Equal(base.Add(2 * time.Minute)) {
+			t.Errorf("task %v next %v, want 2m", i, tk.TaskTime().Sub(base))
+		}
+	}
+}
+
+func TestTaskQueue_RateLimitFIFO(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	tq.SetRateLimit("db", ratelimit.Limit{MaxConcurrent: 1})
+	zone := make(chan func(), 4)
+	tq.SetExecutor(executor.Channel(zone))
+	now := time.Now()
+	var ran []string
+	push := func(name string) {
+		tk := humantimetask.New(now, nil, func(tt *humantimetask.Task) error {
+			ran = append(ran, name)
+			return nil
+		})
+		tk.SetLabel("db")
+		tq.Push(tk)
+	}
+	push("a")
+	tq.processTasks()
+	push("b")
+	push("c")
+	tq.processTasks()
+	(<-zone)() // a ends, hand slot to b
+	push("d")
+	tq.processTasks()
+	if len(zone) != 1 {
+		t.Fatalf("slot not handed to waiter, %v in zone", len(zone))
+	}
+	for len(zone) > 0 {
+		(<-zone)() // handed waiter goes to zone on next processTasks
+		tq.processTasks()
+	}
+	tq.runTasksEndWaitGroup.Wait()
+	if len(ran) != 4 || ran[0] != "a" || ran[1] != "b" || ran[2] != "c" || ran[3] != "d" {
+		t.Errorf("ran %v, want a b c d", ran)
+	}
+}
+
+func TestTaskQueue_RateLimitUnbufferedExecutor(t *testing.T) {
+	tq := New("test", time.Second, 10*time.Millisecond, testLogger{t})
+	tq.SetRateLimit("db", ratelimit.Limit{MaxConcurrent: 1})
+	zone := make(chan func())
+	tq.SetExecutor(executor.Channel(zone))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { // game loop
+		for {
+			select {
+			case fn := <-zone:
+				fn()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	ran := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		i := i
+		tk := humantimetask.New(time.Now(), nil, func(tt *humantimetask.Task) error {
+			ran <- i
+			return nil
+		})
+		tk.SetLabel("db")
+		tq.Push(tk)
+	}
+	go tq.Run(ctx)
+	for i := 1; i <= 2; i++ {
+		select {
+		case got := <-ran:
+			if got != i {
+				t.Errorf("ran %v, want %v", got, i)
+			}
+		case <-ctx.Done():
+			t.Fatalf("task %v not run, loop blocked", i)
+		}
+	}
+}
+
+func TestTaskQueue_SerialKeyExecutor(t *testing.T) {
+	tq := New("test", time.Second, 10*time.Millisecond, testLogger{t})
+	zone := make(chan func())
+	tq.SetExecutor(executor.Channel(zone))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { // game loop
+		for {
+			select {
+			case fn := <-zone:
+				fn()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	ran := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		i := i
+		tk := humantimetask.New(time.Now(), nil, func(tt *humantimetask.Task) error {
+			ran <- i
+			return nil
+		})
+		tk.SetSerialKey("player1")
+		tq.Push(tk)
+	}
+	go tq.Run(ctx)
+	for i := 1; i <= 2; i++ {
+		select {
+		case got := <-ran:
+			if got != i {
+				t.Errorf("ran %v, want %v", got, i)
+			}
+		case <-ctx.Done():
+			t.Fatalf("serial task %v not run, loop blocked", i)
+		}
+	}
+}
+
+func TestTaskQueue_Executor(t *testing.T) {
+	tq := New("test", time.Second, time.Second, testLogger{t})
+	zone := make(chan func(), 1)
+	tq.SetExecutor(executor.Channel(zone))
+	inZone, ranInZone := false, false
+	tt := humantimetask.New(time.Now(), nil, func(tt *humantimetask.Task) error {
+		ranInZone = inZone
+		return nil
+	})
+	tq.Push(tt)
+	tq.processTasks()
+	inZone = true
+	(<-zone)()
+	inZone = false
+	tq.runTasksEndWaitGroup.Wait()
+	st := tq.GetTaskStat().GetStat(tt.GetTaskFnName())
+	if !ranInZone || st == nil || st.SuccessCount != 1 {
+		t.Errorf("not run in zone %v %+v", ranInZone, st)
+	}
+
+	tq.SetExecutor(executor.Func(func(run func()) error {
+		return executor.ErrRejected
+	}))
+	h := tq.PushWithHandle(humantimetask.New(time.Now(), nil, func(tt *humantimetask.Task) error {
+		return nil
+	}))
+	tq.processTasks()
+	tq.runTasksEndWaitGroup.Wait()
+	if !errors.Is(h.Err(), executor.ErrRejected) {
+		t.Errorf("not rejected %v", h)
+	}
+}
+
+func TestTaskQueue_Simulate(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := New("test", time.Second, time.Second, testLogger{t}).Simulate(start); !errors.Is(err, simulation.ErrRealClock) {
+		t.Errorf("real clock %v", err)
+	}
+
+	clk := clock.NewFake(start)
+	tq := NewWithClock("test", time.Second, time.Second, testLogger{t}, clk)
+	var ran []time.Time
+	tq.Push(humantimetask.NewRepeat(start.Add(time.Hour),
+		humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: 24 * time.Hour},
+		nil, func(tt *humantimetask.Task) error {
+			ran = append(ran, clk.Now())
+			return nil
+		}))
+	end := start.Add(7 * 24 * time.Hour)
+	rp, err := tq.Simulate(end)
+	if err != nil || rp.Stopped != simulation.StopEndTime || rp.Run != 7 || !rp.To.Equal(end) {
+		t.Errorf("%v %v", rp, err)
+	}
+	for i, at := range ran {
+		if !at.Equal(start.Add(time.Hour + time.Duration(i)*24*time.Hour)) {
+			t.Errorf("run %v at %v", i, at)
+		}
+	}
+	if tq.Len() != 1 {
+		t.Errorf("repeat task not in queue %v", tq.Len())
+	}
+}
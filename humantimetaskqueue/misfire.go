@@ -0,0 +1,44 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetaskqueue
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/kasworld/timedtask/humantimetask"
+	"github.com/kasworld/timedtask/misfire"
+)
+
+// popDelay 보다 늦게 꺼낸 task 에 misfire policy 를 적용한다. 지금 실행 하면 true
+func (tq *TaskQueue) applyMisfire(t *humantimetask.Task, now time.Time) bool {
+	tq.taskStat.Misfire(t.GetTaskFnName())
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	policy := t.MisfirePolicy().Or(tq.misfirePolicy)
+	act := t.PrepareMisfire(now, policy)
+	if act != misfire.ActionRun {
+		tq.log.Debug("%v misfire %v %v %v", tq.Name, t, policy, act)
+	}
+	switch act {
+	case misfire.ActionRequeue:
+		t.ApplyJitter(tq.jitter)
+		heap.Push(&tq.pQueue, t)
+		return false
+	case misfire.ActionDrop:
+		delete(tq.taskByID, t.ID())
+		t.Handle().Cancel()
+		tq.prerequisiteEnded(t, false)
+		return false
+	}
+	return true
+}
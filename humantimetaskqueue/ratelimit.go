@@ -0,0 +1,70 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetaskqueue
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/kasworld/timedtask/humantimetask"
+	"github.com/kasworld/timedtask/ratelimit"
+)
+
+// key 는 task 의 LimitKey, label 이 없으면 함수 이름
+// MaxConcurrent 를 넘은 task 는 같은 key 의 task 가 끝날때 까지,
+// MaxPerSecond 를 넘은 task 는 다음 시작 할수 있는 시간 까지 미룬다.
+func (tq *TaskQueue) SetRateLimit(key string, limit ratelimit.Limit) {
+	tq.rateLimit.SetLimit(key, limit)
+}
+
+func (tq *TaskQueue) GetRateLimit(key string) ratelimit.Limit {
+	return tq.rateLimit.GetLimit(key)
+}
+
+// 제한을 넘은 task 면 미루고 true
+func (tq *TaskQueue) deferByLimit(t *humantimetask.Task, now time.Time) bool {
+	ok, wait := tq.rateLimit.TryStart(t.LimitKey(), t, now)
+	if ok {
+		return false
+	}
+	tq.taskStat.Defer(t.GetTaskFnName())
+	if wait == 0 { // limitEnded 에서 자리를 넘겨 받는다.
+		tq.log.Debug("%v defer %v, %v max concurrent", tq.Name, t, t.LimitKey())
+		return true
+	}
+	tq.mutex.Lock()
+	t.DeferTo(now.Add(wait))
+	heap.Push(&tq.pQueue, t)
+	tq.mutex.Unlock()
+	tq.log.Debug("%v defer %v, %v max per second", tq.Name, t, t.LimitKey())
+	return true
+}
+
+// deferByLimit 를 지나 실행한 task 가 끝났다. 기다리던 task 가 있으면 자리를 넘긴다.
+// 끝난 task 를 실행한 goroutine 에서 부르므로 넘겨 받은 task 는 Run 이 실행한다.
+func (tq *TaskQueue) limitEnded(t *humantimetask.Task) {
+	if next := tq.releaseLimit(t); next != nil {
+		tq.mutex.Lock()
+		tq.handOff(next, false)
+		tq.mutex.Unlock()
+	}
+}
+
+// t 가 가진 rate limit 자리를 돌려준다. 기다리던 task 가 있으면 자리를 넘겨 받은 task 를 돌려준다.
+// 넘겨 받은 task 는 다시 TryStart 하지 않는다.
+func (tq *TaskQueue) releaseLimit(t *humantimetask.Task) *humantimetask.Task {
+	next, ok := tq.rateLimit.End(t.LimitKey())
+	if !ok {
+		return nil
+	}
+	return next
+}
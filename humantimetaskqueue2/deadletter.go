@@ -0,0 +1,79 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetaskqueue2
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kasworld/timedtask/deadletter"
+	"github.com/kasworld/timedtask/humantimetask"
+)
+
+// 이 보다 많으면 오래된 것 부터 버린다.
+const deadLetterMaxLen = 1000
+
+// retry 를 다 하고도 실패 하거나 panic 한 task 를 보관한다. mutex 안에서 부른다.
+func (tq *TaskQueue) keepDeadLetter(t *humantimetask.Task, err error) {
+	e := deadletter.Entry[*humantimetask.Task]{
+		Task:     t.Snapshot(), // 반복 task 는 다시 queue 에 들어가 바뀐다.
+		FnName:   t.GetTaskFnName(),
+		Argument: t.Argument(),
+		Err:      err,
+		Attempt:  t.Attempt(),
+	}
+	var pe *humantimetask.PanicError
+	if errors.As(err, &pe) {
+		e.Panic = pe.Recovered
+		e.Stack = pe.Stack
+	}
+	id := tq.deadLetter.Add(e)
+	tq.logger.Warn("%v keep dead letter %v %v", tq.Name, id, t)
+}
+
+func (tq *TaskQueue) GetDeadLetterStore() *deadletter.Store[*humantimetask.Task] {
+	return tq.deadLetter
+}
+
+// 보관한 순서
+func (tq *TaskQueue) ListDeadLetter() []deadletter.Entry[*humantimetask.Task] {
+	return tq.deadLetter.List()
+}
+
+func (tq *TaskQueue) GetDeadLetter(id deadletter.EntryID) (deadletter.Entry[*humantimetask.Task], bool) {
+	return tq.deadLetter.Get(id)
+}
+
+// 보관한 task 를 tasktime 에 다시 실행 하도록 넣는다. 시도 횟수는 처음 부터 센다.
+func (tq *TaskQueue) RequeueDeadLetter(id deadletter.EntryID, tasktime time.Time) error {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	e, exist := tq.deadLetter.Get(id)
+	if !exist {
+		return fmt.Errorf("%v requeue failed, not found dead letter %v", tq, id)
+	}
+	if _, inQueue := tq.taskByID[e.Task.ID()]; inQueue { // 반복 task 가 아직 queue 에 있거나 실행중
+		return fmt.Errorf("%v requeue failed, %v already in queue", tq, e.Task)
+	}
+	if _, exist := tq.deadLetter.Take(id); !exist {
+		return fmt.Errorf("%v requeue failed, not found dead letter %v", tq, id)
+	}
+	e.Task.ResetAttempt()
+	e.Task.SetTaskTime(tasktime)
+	tq.push(e.Task)
+	return nil
+}
+
+func (tq *TaskQueue) DiscardDeadLetter(id deadletter.EntryID) bool {
+	return tq.deadLetter.Discard(id)
+}
@@ -0,0 +1,111 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetaskqueue2
+
+import (
+	"container/heap"
+	"fmt"
+
+	"github.com/kasworld/timedtask/humantimetask"
+	"github.com/kasworld/timedtask/taskdep"
+)
+
+// prereqs 가 모두 성공 해야 t 를 실행 한다. t 의 시간이 되어도 기다린다.
+// prereqs 는 이 queue 에 넣었고 아직 끝나지 않은 task 이어야 한다.
+// prereqs 중 하나가 실패 하거나 취소 되면 onFail 에 따라 t 를 취소 하거나 넘어간다.
+func (tq *TaskQueue) PushAfter(t *humantimetask.Task, onFail taskdep.FailPolicy, prereqs ...*humantimetask.Task) error {
+	if t == nil {
+		tq.logger.Fatal("%v tried to push nil task", tq)
+	}
+
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	if t.IsValid() {
+		tq.logger.Fatal("%v tried to push %v already pushed", tq, t)
+	}
+	ids := make([]humantimetask.TaskID, 0, len(prereqs))
+	for _, p := range prereqs {
+		if p == nil || tq.taskByID[p.ID()] != p {
+			return fmt.Errorf("%v push after failed, prerequisite %v not in queue", tq, p)
+		}
+		ids = append(ids, p.ID())
+	}
+	tq.taskDep.Add(t.AssignID(), onFail, ids...)
+	t.ApplyJitter(tq.jitter)
+	heap.Push(&tq.pQueue, t)
+	tq.taskByID[t.ID()] = t
+	if tq.pQueue[0] == t {
+		tq.scheduleTimerAtRootTick()
+	}
+	return nil
+}
+
+// 선행 task 를 기다려야 하면 기억하고 true, 선행 task 가 끝나면 다시 넣는다.
+func (tq *TaskQueue) holdForPrerequisite(t *humantimetask.Task) bool {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	return tq.taskDep.Hold(t.ID())
+}
+
+// 선행 task, rate limit, serial key 를 기다리던 task 면 지우고 true, mutex 안에서 부른다.
+func (tq *TaskQueue) removeWaiting(t *humantimetask.Task) bool {
+	switch {
+	case tq.taskDep.Unhold(t.ID()):
+	case tq.rateLimit.RemoveWaiting(t.LimitKey(), t):
+	case tq.serialKey.RemoveWaiting(t.SerialKey(), t):
+		if next := tq.releaseLimit(t); next != nil { // rate limit 을 지나서 기다렸다.
+			tq.handOff(next, false)
+		}
+	default:
+		return false
+	}
+	delete(tq.taskByID, t.ID())
+	tq.leaveScope(t)
+	t.Handle().Cancel()
+	tq.prerequisiteEnded(t, false)
+	return true
+}
+
+// 끝나거나 지워진 task 의 후행 task 들을 처리한다. mutex 안에서 부른다.
+func (tq *TaskQueue) prerequisiteEnded(t *humantimetask.Task, succeeded bool) {
+	var rs taskdep.Result[humantimetask.TaskID]
+	if succeeded {
+		rs = tq.taskDep.Succeeded(t.ID())
+	} else {
+		rs = tq.taskDep.Failed(t.ID())
+	}
+	for _, id := range rs.Ready {
+		if dt := tq.taskByID[id]; dt != nil {
+			dt.DeferTo(tq.currentTime()) // 기다린 시간은 misfire 가 아니다.
+			tq.pushAndSchedule(dt)
+		}
+	}
+	for _, id := range append(rs.Cancelled, rs.Skipped...) {
+		dt := tq.taskByID[id]
+		if dt == nil {
+			continue
+		}
+		if dt.IsValid() {
+			oldroot := tq.pQueue[0]
+			tq.pQueue.Remove(dt)
+			if oldroot == dt {
+				tq.scheduleTimerAtRootTick()
+			}
+		} else {
+			tq.removeHeld(dt)
+		}
+		delete(tq.taskByID, id)
+		tq.leaveScope(dt)
+		dt.Handle().Cancel()
+		tq.logger.Debug("%v %v not run, prerequisite %v not succeeded", tq.Name, dt, t)
+	}
+}
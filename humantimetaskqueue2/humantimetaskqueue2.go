@@ -13,15 +13,25 @@
 package humantimetaskqueue2
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/kasworld/actpersec"
+	"github.com/kasworld/timedtask/clock"
+	"github.com/kasworld/timedtask/deadletter"
+	"github.com/kasworld/timedtask/executor"
 	"github.com/kasworld/timedtask/humantimetask"
 	"github.com/kasworld/timedtask/humantimetaskqueuei"
 	"github.com/kasworld/timedtask/loggeri"
+	"github.com/kasworld/timedtask/misfire"
+	"github.com/kasworld/timedtask/ratelimit"
+	"github.com/kasworld/timedtask/retrypolicy"
+	"github.com/kasworld/timedtask/serialkey"
+	"github.com/kasworld/timedtask/taskdep"
 	"github.com/kasworld/timedtask/taskstat"
+	"github.com/kasworld/timedtask/workerpool"
 )
 
 var _ humantimetaskqueuei.TaskQueueI = &TaskQueue{}
@@ -34,26 +44,58 @@ type TaskQueue struct {
 	mutex                sync.RWMutex
 	runTasksEndWaitGroup sync.WaitGroup // 실행중인 task가 모두 끝났음을 보장
 
-	logger   loggeri.LoggerI
-	Name     string
-	runStat  *actpersec.ActPerSec
-	taskStat *taskstat.TaskStat
-	pQueue   humantimetask.TaskList
-	paused   bool
+	logger      loggeri.LoggerI
+	Name        string
+	runStat     *actpersec.ActPerSec
+	runCtx      context.Context // Run 의 ctx, task 실행에 쓴다.
+	taskStat    *taskstat.TaskStat
+	deadLetter  *deadletter.Store[*humantimetask.Task]
+	pQueue      humantimetask.TaskList
+	taskByID    map[humantimetask.TaskID]*humantimetask.Task // pushed, not ended
+	taskDep     *taskdep.Graph[humantimetask.TaskID]
+	rateLimit   *ratelimit.Limiter[*humantimetask.Task]
+	serialKey   *serialkey.Lanes[*humantimetask.Task]
+	handoffs    []handoff // rate limit, serial key 자리를 넘겨 받아 Run 이 실행할 task
+	wakeHandoff chan struct{}
+	executor    executor.Executor // nil : executor.Goroutine
+	workerPool  *workerpool.Pool  // SetWorkerPool 로 만든 pool
+	taskScope   map[humantimetask.TaskID]*Scope
+	paused      bool
+	flushing    bool      // FlushTaskTill 중, flushTime 을 지금으로 본다.
+	flushTime   time.Time // FlushTaskTill 이 실행 하는 task 의 지금 시간
 
-	popDelay  time.Duration
-	tasktimer *time.Timer
+	popDelay       time.Duration
+	defaultTimeout time.Duration              // 0 : no timeout
+	retryPolicy    *retrypolicy.Policy        // nil : no retry
+	panicHandler   humantimetask.PanicHandler // nil : humantimetask.StderrPanic
+	misfirePolicy  misfire.Policy             // Default : misfire.RunNow
+	jitter         humantimetask.Jitter       // Window 0 : no jitter
+	clock          clock.Clock
+	tasktimer      clock.Timer
 }
 
 func New(name string, popDelay time.Duration, logger loggeri.LoggerI) *TaskQueue {
+	return NewWithClock(name, popDelay, logger, clock.Real{})
+}
+
+// clk 으로 시간을 재고 기다린다. test 에서는 clock.Fake
+func NewWithClock(name string, popDelay time.Duration, logger loggeri.LoggerI, clk clock.Clock) *TaskQueue {
 	tq := &TaskQueue{
-		logger:    logger,
-		Name:      name,
-		runStat:   actpersec.New(),
-		taskStat:  taskstat.New(),
-		pQueue:    make(humantimetask.TaskList, 0),
-		popDelay:  popDelay,
-		tasktimer: time.NewTimer(timeDurationYear), // after a year
+		logger:      logger,
+		Name:        name,
+		runStat:     actpersec.New(),
+		taskStat:    taskstat.New(),
+		deadLetter:  deadletter.New[*humantimetask.Task](deadLetterMaxLen),
+		pQueue:      make(humantimetask.TaskList, 0),
+		taskByID:    make(map[humantimetask.TaskID]*humantimetask.Task),
+		taskDep:     taskdep.New[humantimetask.TaskID](),
+		rateLimit:   ratelimit.New[*humantimetask.Task](),
+		serialKey:   serialkey.New[*humantimetask.Task](),
+		wakeHandoff: make(chan struct{}, 1),
+		taskScope:   make(map[humantimetask.TaskID]*Scope),
+		popDelay:    popDelay,
+		clock:       clk,
+		tasktimer:   clk.NewTimer(timeDurationYear), // after a year
 	}
 	return tq
 }
@@ -61,8 +103,8 @@ func New(name string, popDelay time.Duration, logger loggeri.LoggerI) *TaskQueue
 func (tq TaskQueue) String() string {
 	pstr := TrueString(tq.paused, "paused", "running")
 	return fmt.Sprintf(
-		"HumanTimeTaskQueue2[%v %s %v %v]",
-		tq.Name, pstr, tq.Len(), tq.runStat)
+		"HumanTimeTaskQueue2[%v %s %v %v%s]",
+		tq.Name, pstr, tq.Len(), tq.runStat, tq.workerPoolString())
 }
 
 func TrueString(b bool, truestr, falsestr string) string {
@@ -72,3 +114,38 @@ func TrueString(b bool, truestr, falsestr string) string {
 		return falsestr
 	}
 }
+
+// timeout 이 없는 task 의 실행 시간 제한, 넘으면 ctx 를 취소하고 기다리지 않는다.
+func (tq *TaskQueue) SetDefaultTimeout(timeout time.Duration) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.defaultTimeout = timeout
+}
+
+// 실패한 task 를 다시 실행하는 기본 policy, task 의 policy 가 우선 한다. nil 이면 다시 하지 않는다.
+func (tq *TaskQueue) SetRetryPolicy(p *retrypolicy.Policy) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.retryPolicy = p
+}
+
+// task 가 panic 하면 부른다. humantimetask.LogPanic, RePanic, TerminatePanic 이나 직접 만든 handler
+func (tq *TaskQueue) SetPanicHandler(h humantimetask.PanicHandler) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.panicHandler = h
+}
+
+// popDelay 보다 늦게 꺼낸 task 의 기본 처리, task 의 policy 가 우선 한다.
+func (tq *TaskQueue) SetMisfirePolicy(p misfire.Policy) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.misfirePolicy = p
+}
+
+// jitter 가 없는 task 에 Push, reschedule 할때 적용한다.
+func (tq *TaskQueue) SetJitter(jt humantimetask.Jitter) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	tq.jitter = jt
+}
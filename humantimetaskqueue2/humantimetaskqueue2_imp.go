@@ -19,6 +19,7 @@ import (
 
 	"github.com/kasworld/actpersec"
 	"github.com/kasworld/timedtask/humantimetask"
+	"github.com/kasworld/timedtask/taskhandle"
 	"github.com/kasworld/timedtask/taskstat"
 )
 
@@ -45,16 +46,33 @@ func (tq *TaskQueue) Peek() *humantimetask.Task {
 	return nil
 }
 
+// 꺼낸 task 는 queue 가 더 관리하지 않는다. GetByID 로 찾을수 없다.
+// 붙은 handle 은 Cancelled 로 끝난다.
 func (tq *TaskQueue) Pop() *humantimetask.Task {
 	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	t := tq.pop()
+	if t != nil {
+		delete(tq.taskByID, t.ID())
+		t.Handle().Cancel()
+		tq.leaveScope(t)
+	}
+	return t
+}
+
+// 실행 하려고 꺼낸다. 끝날때 까지 taskByID 에 남는다.
+func (tq *TaskQueue) popToRun() *humantimetask.Task {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	return tq.pop()
+}
+
+// mutex 안에서 부른다.
+func (tq *TaskQueue) pop() *humantimetask.Task {
 	if len(tq.pQueue) == 0 {
-		tq.mutex.Unlock()
 		return nil
 	}
-	t := tq.pQueue[0]
-	_ = heap.Pop(&tq.pQueue)
-	tq.mutex.Unlock()
-	return t
+	return heap.Pop(&tq.pQueue).(*humantimetask.Task)
 }
 
 func (tq *TaskQueue) Len() int {
@@ -89,7 +107,12 @@ func (tq *TaskQueue) UpdateTaskArgAndTime(t *humantimetask.Task, uparg interface
 	}
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
-	return tq.update(t, uparg, uptime)
+	jittered, offset := t.JitterTime(uptime, tq.jitter)
+	if err := tq.update(t, uparg, jittered); err != nil {
+		return err // jitter 는 바꾸지 않는다.
+	}
+	t.SetJitterOffset(offset)
+	return nil
 }
 
 func (tq *TaskQueue) UpdateTaskTime(t *humantimetask.Task, uptime time.Time) error {
@@ -98,7 +121,12 @@ func (tq *TaskQueue) UpdateTaskTime(t *humantimetask.Task, uptime time.Time) err
 	}
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
-	return tq.update(t, t.Argument(), uptime)
+	jittered, offset := t.JitterTime(uptime, tq.jitter)
+	if err := tq.update(t, t.Argument(), jittered); err != nil {
+		return err // jitter 는 바꾸지 않는다.
+	}
+	t.SetJitterOffset(offset)
+	return nil
 }
 
 func (tq *TaskQueue) update(t *humantimetask.Task, uparg interface{}, uptime time.Time) error {
@@ -124,11 +152,37 @@ func (tq *TaskQueue) Remove(t *humantimetask.Task) error {
 
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
+	return tq.remove(t)
+}
+
+func (tq *TaskQueue) remove(t *humantimetask.Task) error {
+	if !t.IsValid() {
+		if tq.removeWaiting(t) {
+			return nil
+		}
+		if tq.removeHeld(t) {
+			delete(tq.taskByID, t.ID())
+			t.Handle().Cancel()
+			tq.prerequisiteEnded(t, false)
+			tq.leaveScope(t)
+			return nil
+		}
+		if tq.taskByID[t.ID()] == t {
+			// running task, cancel ctx. taskEnded 에서 retry, repeat 하지 않고 끝낸다.
+			delete(tq.taskByID, t.ID())
+			t.CancelRun()
+			return nil
+		}
+	}
 	if len(tq.pQueue) > 0 {
 		oldroot := tq.pQueue[0]
 		if err := tq.pQueue.Remove(t); err != nil {
 			return err
 		}
+		delete(tq.taskByID, t.ID())
+		t.Handle().Cancel()
+		tq.prerequisiteEnded(t, false)
+		tq.leaveScope(t)
 		if oldroot == t {
 			tq.scheduleTimerAtRootTick()
 		}
@@ -138,6 +192,28 @@ func (tq *TaskQueue) Remove(t *humantimetask.Task) error {
 	return nil
 }
 
+func (tq *TaskQueue) GetByID(id humantimetask.TaskID) *humantimetask.Task {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	return tq.taskByID[id]
+}
+
+func (tq *TaskQueue) RemoveByID(id humantimetask.TaskID) error {
+	t := tq.GetByID(id)
+	if t == nil {
+		return fmt.Errorf("%v remove failed, not found task %v", tq, id)
+	}
+	return tq.Remove(t)
+}
+
+func (tq *TaskQueue) RescheduleByID(id humantimetask.TaskID, uptime time.Time) error {
+	t := tq.GetByID(id)
+	if t == nil {
+		return fmt.Errorf("%v reschedule failed, not found task %v", tq, id)
+	}
+	return tq.UpdateTaskTime(t, uptime)
+}
+
 func (tq *TaskQueue) Push(t *humantimetask.Task) {
 	if t == nil {
 		tq.logger.Fatal("%v tried to push nil task", tq)
@@ -145,11 +221,28 @@ func (tq *TaskQueue) Push(t *humantimetask.Task) {
 
 	tq.mutex.Lock()
 	defer tq.mutex.Unlock()
+	tq.push(t)
+}
+
+// mutex 안에서 부른다.
+func (tq *TaskQueue) push(t *humantimetask.Task) {
 	if t.IsValid() {
 		tq.logger.Fatal("%v tried to push %v already pushed", tq, t)
 	}
+	if t.TaskTime().IsZero() {
+		tq.logger.Fatal("%v tried to push %v without time", tq, t)
+	}
+	t.ApplyJitter(tq.jitter)
 	heap.Push(&tq.pQueue, t)
+	tq.taskByID[t.AssignID()] = t
 	if tq.pQueue[0] == t {
 		tq.scheduleTimerAtRootTick()
 	}
 }
+
+// 끝나기를 기다리고 결과를 받을 handle 을 붙여서 넣는다.
+func (tq *TaskQueue) PushWithHandle(t *humantimetask.Task) *taskhandle.Handle {
+	h := t.AttachHandle()
+	tq.Push(t)
+	return h
+}
@@ -12,17 +12,34 @@
 package humantimetaskqueue2
 
 import (
+	"container/heap"
 	"context"
+	"errors"
 	"time"
 
+	"github.com/kasworld/timedtask/clock"
 	"github.com/kasworld/timedtask/humantimetask"
+	"github.com/kasworld/timedtask/taskstat"
 )
 
 func (tq *TaskQueue) Run(ctx context.Context) {
 	tq.logger.TraceService("Start Run %v", tq)
 	defer func() { tq.logger.TraceService("End Run %v", tq) }()
 
-	tk1sec := time.NewTicker(1 * time.Second)
+	// clock.Fake 의 Advance 는 받은 값을 처리하고 timer 를 다시 맞출 때 까지 기다린다.
+	tq.mutex.Lock()
+	tq.runCtx = ctx
+	clock.Ack(tq.tasktimer)
+	tq.scheduleTimerAtRootTick() // 전의 Run 이 끝나며 Stop 했다.
+	tq.mutex.Unlock()
+	defer func() { // Run 이 끝난 뒤의 FlushTaskTill 은 취소된 ctx 를 받지 않는다.
+		tq.mutex.Lock()
+		tq.runCtx = nil
+		tq.mutex.Unlock()
+	}()
+	defer tq.tasktimer.Stop()
+
+	tk1sec := tq.clock.NewTicker(1 * time.Second)
 	defer tk1sec.Stop()
 
 	for {
@@ -30,7 +47,7 @@ func (tq *TaskQueue) Run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 
-		case <-tq.tasktimer.C:
+		case <-tq.tasktimer.C():
 			tq.processTasks()
 
 			tq.mutex.RLock()
@@ -40,20 +57,25 @@ func (tq *TaskQueue) Run(ctx context.Context) {
 				tq.scheduleTimerAtRootTick()
 			}
 			tq.mutex.RUnlock()
+			clock.Ack(tq.tasktimer)
 
-		case <-tk1sec.C:
+		case <-tk1sec.C():
 			tq.runStat.UpdateLap()
 
+		case <-tq.wakeHandoff:
+			tq.startHandoffs(tq.dispatch)
+
 		}
 	}
 }
 
 func (tq *TaskQueue) processTasks() {
 	tq.logger.Debug("%v processTasks", tq)
-	startTime := time.Now().UTC()
+	tq.startHandoffs(tq.dispatch) // 자리를 넘겨 받아 기다리던 task 먼저
+	startTime := tq.clock.Now().UTC()
 
 	for {
-		thisTime := time.Now().UTC()
+		thisTime := tq.clock.Now().UTC()
 
 		peeked := tq.Peek()
 		if peeked == nil { // no task to do
@@ -63,26 +85,157 @@ func (tq *TaskQueue) processTasks() {
 			return
 		}
 
-		t := tq.Pop()
+		t := tq.popToRun()
 		if t == nil {
 			tq.logger.Warn("%v task nil %v", tq, t)
 			continue
 		}
-		delay := thisTime.Sub(t.TaskTime())
-		if delay > tq.popDelay {
-			tq.logger.Warn("%v Delayed Pop %v %v", tq, t, delay)
+		if tq.admit(t, thisTime) {
+			tq.dispatch(t)
 		}
+	}
+}
 
-		tq.runTasksEndWaitGroup.Add(1)
-		go tq.runWaitTask(t)
+// 꺼낸 task 를 지금 실행 해도 되면 true, 기다리게 하거나 버린 task 는 false.
+// Run 과 FlushTaskTill 이 같이 쓴다.
+func (tq *TaskQueue) admit(t *humantimetask.Task, now time.Time) bool {
+	if tq.holdInPausedScope(t) {
+		return false
+	}
+	if tq.holdForPrerequisite(t) {
+		return false
+	}
+	if tq.dropExpired(t, now) {
+		return false
 	}
+	delay := now.Sub(t.TaskTime())
+	if delay > tq.popDelay {
+		tq.logger.Warn("%v Delayed Pop %v %v", tq, t, delay)
+		if !tq.applyMisfire(t, now) {
+			return false
+		}
+	}
+	if tq.deferByLimit(t, now) {
+		return false
+	}
+	return !tq.holdForSerialKey(t)
 }
 
 func (tq *TaskQueue) runWaitTask(t *humantimetask.Task) {
 	defer tq.runTasksEndWaitGroup.Done()
 	tq.runStat.Inc()
 	tso := tq.taskStat.GetStatByFuncName(t.GetTaskFnName())
-	t.RunWithStat(tso)
+	t.Handle().Start()
+	err := t.RunWithClock(tq.runContext(), tso, tq.taskTimeout(t), tq.clock)
+	if errors.Is(err, humantimetask.ErrTimeout) {
+		tq.logger.Error("%v", err)
+	}
+	tq.afterRun(t, func(lateErr error) {
+		if humantimetask.IsPanic(lateErr) { // timeout 뒤에 panic 했다.
+			err = lateErr
+			tq.logger.Error("%v", err)
+		}
+		defer tq.handlePanic(err) // RePanic 이어도 정리는 끝낸다.
+		tq.taskEnded(t, tso, err)
+		tq.limitEnded(t)
+		tq.serialEnded(t)
+	})
+}
+
+// 실행이 끝난 task 를 end 로 정리한다.
+// timeout 으로 기다리지 않은 실행이 남아 있으면 그 실행이 끝난 뒤에 정리해야
+// retry, repeat 로 다시 넣은 task 가 남은 실행과 겹치지 않는다.
+// 남은 실행의 결과를 lateErr 로 준다.
+func (tq *TaskQueue) afterRun(t *humantimetask.Task, end func(lateErr error)) {
+	wait := t.RunWaiter()
+	if wait == nil {
+		end(nil)
+		return
+	}
+	go func() {
+		end(wait())
+	}()
+}
+
+// panic 으로 끝났으면 panic handler 를 부른다.
+func (tq *TaskQueue) handlePanic(err error) {
+	var pe *humantimetask.PanicError
+	if !errors.As(err, &pe) {
+		return
+	}
+	tq.mutex.RLock()
+	h := tq.panicHandler
+	tq.mutex.RUnlock()
+	if h == nil {
+		h = humantimetask.StderrPanic
+	}
+	h(pe.Task, pe.Recovered, pe.Stack)
+}
+
+// task 에 timeout 이 없으면 queue 의 기본값
+func (tq *TaskQueue) taskTimeout(t *humantimetask.Task) time.Duration {
+	if t.Timeout() > 0 {
+		return t.Timeout()
+	}
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	return tq.defaultTimeout
+}
+
+// processTasks 에서 실행하는 task 의 ctx, Run 중이 아니면 Background
+func (tq *TaskQueue) runContext() context.Context {
+	tq.mutex.RLock()
+	defer tq.mutex.RUnlock()
+	if tq.runCtx == nil {
+		return context.Background()
+	}
+	return tq.runCtx
+}
+
+// 실행이 끝난 task 정리, 실패한 task 는 retry policy 에 따라
+// 반복 task 면 다음 시간으로 다시 넣는다.
+func (tq *TaskQueue) taskEnded(t *humantimetask.Task, tso *taskstat.StatObj, err error) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+	if t.IsValid() { // pushed again in DoTaskFn
+		t.Handle().Requeue()
+		return
+	}
+	if tq.taskByID[t.ID()] != t { // removed while running
+		t.ResetAttempt()
+		t.Handle().Cancel()
+		tq.prerequisiteEnded(t, false)
+		tq.leaveScope(t)
+		return
+	}
+	if err != nil && t.PrepareRetry(err, tq.currentTime(), tq.retryPolicy) {
+		tso.Retry()
+		t.Handle().Requeue()
+		tq.pushAndSchedule(t)
+		return
+	}
+	if err != nil {
+		tq.keepDeadLetter(t, err)
+	}
+	t.ResetAttempt()
+	if t.PrepareNextRepeat(tq.currentTime()) {
+		t.Handle().Requeue()
+		t.ApplyJitter(tq.jitter)
+		tq.pushAndSchedule(t)
+		return
+	}
+	t.Handle().Finish(err)
+	tq.prerequisiteEnded(t, err == nil)
+	delete(tq.taskByID, t.ID())
+	tq.leaveScope(t)
+}
+
+// 다시 넣은 task 가 root 가 되면 timer 를 다시 맞춘다.
+func (tq *TaskQueue) pushAndSchedule(t *humantimetask.Task) {
+	heap.Push(&tq.pQueue, t)
+	if tq.pQueue[0] == t {
+		tq.scheduleTimerAtRootTick()
+	}
 }
 
 func (tq *TaskQueue) scheduleTimerAtRootTick() {
@@ -92,7 +245,7 @@ func (tq *TaskQueue) scheduleTimerAtRootTick() {
 	d := timeDurationYear
 	if len(tq.pQueue) > 0 {
 		t := tq.pQueue[0].TaskTime()
-		d = t.Sub(time.Now())
+		d = t.Sub(tq.clock.Now())
 	}
 	tq.tasktimer.Reset(d)
 }
@@ -103,7 +256,20 @@ func (tq *TaskQueue) FlushTaskTill(till time.Time) {
 	tq.logger.TraceService("Start FlushTaskTill %v", tq)
 	defer func() { tq.logger.TraceService("End FlushTaskTill %v, %v", processed, tq) }()
 
+	defer func() {
+		tq.mutex.Lock()
+		tq.flushing = false
+		tq.mutex.Unlock()
+	}()
+
+	runNow := func(t *humantimetask.Task) {
+		tq.runTasksEndWaitGroup.Add(1)
+		tq.runWaitTask(t) // executor 를 거치지 않고 이 goroutine 에서 실행한다.
+		processed++
+	}
 	for {
+		for tq.startHandoffs(runNow) > 0 {
+		}
 		peeked := tq.Peek()
 		if peeked == nil { // no task to do
 			return
@@ -112,13 +278,40 @@ func (tq *TaskQueue) FlushTaskTill(till time.Time) {
 			return
 		}
 
-		t := tq.Pop()
+		t := tq.popToRun()
 		if t == nil {
 			continue
 		}
-		tq.runStat.Inc()
-		tso := tq.taskStat.GetStatByFuncName(t.GetTaskFnName())
-		t.RunWithStat(tso)
-		processed++
+		now := tq.flushNow(t, till)
+		if !tq.admit(t, now) {
+			continue
+		}
+		runNow(t)
+	}
+}
+
+// FlushTaskTill 이 t 를 실행 할때의 지금 시간, till 까지 미리 실행 하면 task 의 시간이 지금이다.
+// clock 이 till 을 지났으면 till 에서 멈춘다.
+func (tq *TaskQueue) flushNow(t *humantimetask.Task, till time.Time) time.Time {
+	now := tq.clock.Now()
+	if now.Before(t.TaskTime()) {
+		now = t.TaskTime()
+	}
+	if now.After(till) {
+		now = till
+	}
+	tq.mutex.Lock()
+	tq.flushing = true
+	tq.flushTime = now
+	tq.mutex.Unlock()
+	return now
+}
+
+// 실행이 끝난 task 정리에 쓰는 지금 시간, mutex 안에서 부른다.
+// FlushTaskTill 중이면 실행한 task 의 지금 시간이다.
+func (tq *TaskQueue) currentTime() time.Time {
+	if tq.flushing {
+		return tq.flushTime
 	}
+	return tq.clock.Now()
 }
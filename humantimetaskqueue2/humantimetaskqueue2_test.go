@@ -13,8 +13,242 @@
 package humantimetaskqueue2
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/kasworld/timedtask/clock"
+	"github.com/kasworld/timedtask/humantimetask"
+	"github.com/kasworld/timedtask/taskhandle"
+	"github.com/kasworld/timedtask/workerpool"
 )
 
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Fatal(format string, v ...interface{})        { l.t.Fatalf(format, v...) }
+func (l testLogger) Error(format string, v ...interface{})        { l.t.Logf(format, v...) }
+func (l testLogger) Warn(format string, v ...interface{})         { l.t.Logf(format, v...) }
+func (l testLogger) Debug(format string, v ...interface{})        {}
+func (l testLogger) TraceService(format string, v ...interface{}) {}
+
 func TestNew(t *testing.T) {
 }
+
+func TestTaskQueue_Repeat(t *testing.T) {
+	tq := New("test", time.Second, testLogger{t})
+	base := time.Now().Add(time.Hour)
+	runCount := 0
+	tk := humantimetask.NewRepeat(
+		base, humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: time.Minute, MaxRun: 5},
+		nil, func(tt *humantimetask.Task) error {
+			runCount++
+			return nil
+		})
+	tq.Push(tk)
+	tq.FlushTaskTill(base.Add(2 * time.Minute))
+	if runCount != 3 || tk.RunCount() != 3 {
+		t.Errorf("runCount %v %v, want 3", runCount, tk.RunCount())
+	}
+	if tq.Len() != 1 || !tk.IsValid() {
+		t.Fatalf("repeat task not in queue")
+	}
+	if err := tq.Remove(tk); err != nil {
+		t.Errorf("%v", err)
+	}
+	tq.FlushTaskTill(base.Add(time.Hour))
+	if runCount != 3 {
+		t.Errorf("removed task run %v", runCount)
+	}
+}
+
+func TestTaskQueue_Handle(t *testing.T) {
+	tq := New("test", time.Second, testLogger{t})
+	now := time.Now()
+	errFail := errors.New("fail")
+	ok := tq.PushWithHandle(humantimetask.New(now, nil, func(tt *humantimetask.Task) error {
+		if st := tt.Handle().Status(); st != taskhandle.Running {
+			t.Errorf("status in run %v", st)
+		}
+		return nil
+	}))
+	fail := tq.PushWithHandle(humantimetask.New(now, nil, func(tt *humantimetask.Task) error {
+		return errFail
+	}))
+	removedTask := humantimetask.New(now.Add(time.Hour), nil, func(tt *humantimetask.Task) error {
+		return nil
+	})
+	removed := tq.PushWithHandle(removedTask)
+	if ok.Status() != taskhandle.Pending {
+		t.Errorf("status before run %v", ok.Status())
+	}
+	tq.FlushTaskTill(now)
+	if err := tq.Remove(removedTask); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ok.Wait(ctx); err != nil || ok.Status() != taskhandle.Succeeded {
+		t.Errorf("ok %v %v", err, ok)
+	}
+	if err := fail.Wait(ctx); !errors.Is(err, errFail) || fail.Status() != taskhandle.Failed {
+		t.Errorf("fail %v %v", err, fail)
+	}
+	<-removed.Done()
+	if removed.Err() != taskhandle.ErrCancelled || removed.Status() != taskhandle.Cancelled {
+		t.Errorf("removed %v", removed)
+	}
+
+	popped := tq.PushWithHandle(humantimetask.New(now.Add(time.Hour), nil, func(tt *humantimetask.Task) error {
+		return nil
+	}))
+	tq.Pop()
+	if err := popped.Wait(ctx); err != taskhandle.ErrCancelled || popped.Status() != taskhandle.Cancelled {
+		t.Errorf("popped %v %v", err, popped)
+	}
+}
+
+func TestTaskQueue_Expiry(t *testing.T) {
+	tq := New("test", time.Second, testLogger{t})
+	now := time.Now()
+	ran := 0
+	fn := func(tt *humantimetask.Task) error {
+		ran++
+		return nil
+	}
+	var expired []*humantimetask.Task
+	onExpire := func(tt *humantimetask.Task) {
+		expired = append(expired, tt)
+	}
+	late := humantimetask.New(now.Add(-time.Minute), nil, fn)
+	late.SetExpiry(now.Add(-time.Second), onExpire)
+	h := tq.PushWithHandle(late)
+	inTime := humantimetask.New(now.Add(-time.Minute), nil, fn)
+	inTime.SetExpiry(now.Add(time.Hour), onExpire)
+	tq.Push(inTime)
+	tq.processTasks()
+	tq.runTasksEndWaitGroup.Wait()
+	if ran != 1 || len(expired) != 1 || expired[0] != late {
+		t.Errorf("ran %v, expired %v", ran, expired)
+	}
+	if !errors.Is(h.Err(), humantimetask.ErrExpired) {
+		t.Errorf("handle %v", h)
+	}
+	st := tq.GetTaskStat().GetStat(late.GetTaskFnName())
+	if st == nil || st.ExpireCount != 1 || st.EndCount != 1 {
+		t.Errorf("expire stat %+v", st)
+	}
+}
+
+func TestTaskQueue_WorkerPool(t *testing.T) {
+	tq := New("test", time.Second, testLogger{t})
+	tq.SetWorkerPool(workerpool.Config{Size: 1, Backlog: 1, Saturation: workerpool.Drop})
+	pool := tq.GetWorkerPool()
+	block := make(chan struct{})
+	started := make(chan struct{})
+	for !pool.Submit(func() { close(started); <-block }) {
+	}
+	<-started
+	if !pool.Submit(func() {}) {
+		t.Fatalf("backlog not accepted %v", pool)
+	}
+	h := tq.PushWithHandle(humantimetask.New(time.Now(), nil, func(tt *humantimetask.Task) error {
+		return nil
+	}))
+	tq.processTasks()
+	close(block)
+	tq.runTasksEndWaitGroup.Wait()
+	if err := h.Err(); !errors.Is(err, workerpool.ErrSaturated) || h.Status() != taskhandle.Failed {
+		t.Errorf("not dropped %v %v", err, h)
+	}
+	if st := pool.Stat(); st.Dropped != 1 {
+		t.Errorf("pool stat %v", st)
+	}
+	if len(tq.ListDeadLetter()) != 1 {
+		t.Errorf("dead letter %v", tq.ListDeadLetter())
+	}
+	tq.SetWorkerPool(workerpool.Config{})
+	if tq.GetWorkerPool() != nil {
+		t.Errorf("pool not removed")
+	}
+}
+
+func TestTaskQueue_FakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	tq := NewWithClock("test", time.Second, testLogger{t}, clk)
+	ran := make(chan time.Time, 2)
+	rp := humantimetask.NewRepeat(start.Add(time.Hour),
+		humantimetask.Repeat{Mode: humantimetask.FixedRate, Interval: time.Hour, MaxRun: 2},
+		nil, func(tt *humantimetask.Task) error {
+			ran <- clk.Now()
+			return nil
+		})
+	h := tq.PushWithHandle(rp)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go tq.Run(ctx)
+
+	for i := 1; i <= 2; i++ {
+		clk.Advance(time.Hour)
+		select {
+		case at := <-ran:
+			if !at.Equal(start.Add(time.Duration(i) * time.Hour)) {
+				t.Errorf("run %v at %v", i, at)
+			}
+		case <-ctx.Done():
+			t.Fatalf("run %v not fired", i)
+		}
+	}
+	if err := h.Wait(ctx); err != nil || rp.RunCount() != 2 {
+		t.Errorf("repeat not ended %v %v", err, rp.RunCount())
+	}
+}
+
+func TestTaskQueue_FakeClockTimeout(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	tq := NewWithClock("test", time.Second, testLogger{t}, clk)
+	started := make(chan struct{})
+	ended := make(chan error, 1)
+	ft := humantimetask.NewWithContext(start.Add(time.Hour), nil,
+		func(ctx context.Context, tt *humantimetask.Task) error {
+			close(started)
+			<-ctx.Done()
+			ended <- ctx.Err()
+			return ctx.Err()
+		})
+	ft.SetTimeout(time.Minute)
+	h := tq.PushWithHandle(ft)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go tq.Run(ctx)
+
+	clk.Advance(time.Hour)
+	select {
+	case <-started:
+	case <-ctx.Done():
+		t.Fatalf("task not started")
+	}
+	clk.Advance(30 * time.Second)
+	select {
+	case err := <-ended:
+		t.Fatalf("ended before timeout %v", err)
+	default:
+	}
+	clk.Advance(30 * time.Second)
+	select {
+	case err := <-ended:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ended with %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatalf("timeout not fired by clock")
+	}
+	if err := h.Wait(ctx); err == nil || h.Status() != taskhandle.Failed {
+		t.Errorf("handle %v %v", err, h)
+	}
+}
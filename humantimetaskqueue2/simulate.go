@@ -0,0 +1,97 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package humantimetaskqueue2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasworld/timedtask/clock"
+	"github.com/kasworld/timedtask/simulation"
+)
+
+// Run 대신 부른다. clock.Fake 로 만든 queue 에서만 쓴다.
+// 기다리지 않고 가상 시간을 다음 task 의 시간으로 옮겨 가며 이 goroutine 에서 순서대로 실행한다.
+// 다음 task 가 end 뒤이면 end 까지 옮기고 끝나고, task 가 없으면 그 시간에 끝난다.
+// task 의 timeout, rate limit, serial key, executor 는 쓰지 않는다.
+func (tq *TaskQueue) Simulate(end time.Time) (rp simulation.Report[time.Time], err error) {
+	fake, ok := tq.clock.(*clock.Fake)
+	if !ok {
+		return rp, fmt.Errorf("%v %w", tq, simulation.ErrRealClock)
+	}
+	startTime := time.Now()
+	rp.From = fake.Now()
+	defer func() {
+		rp.To = fake.Now()
+		rp.Elapsed = time.Since(startTime)
+	}()
+
+	for {
+		if tq.IsPaused() {
+			rp.Stopped = simulation.StopPaused
+			return rp, nil
+		}
+		peeked := tq.Peek()
+		if peeked == nil {
+			rp.Stopped = simulation.StopEmpty
+			return rp, nil
+		}
+		next := peeked.TaskTime()
+		if next.After(end) {
+			fake.Set(end)
+			rp.Stopped = simulation.StopEndTime
+			return rp, nil
+		}
+		if next.After(fake.Now()) {
+			fake.Set(next)
+			rp.Steps++
+		}
+		tq.simulateDue(&rp, fake.Now())
+	}
+}
+
+// now 까지 시간이 된 task 를 모두 실행한다.
+func (tq *TaskQueue) simulateDue(rp *simulation.Report[time.Time], now time.Time) {
+	for {
+		peeked := tq.Peek()
+		if peeked == nil || now.Before(peeked.TaskTime()) {
+			return
+		}
+
+		t := tq.popToRun()
+		if t == nil {
+			continue
+		}
+		if tq.holdInPausedScope(t) {
+			continue
+		}
+		if tq.holdForPrerequisite(t) {
+			continue
+		}
+		if tq.dropExpired(t, now) {
+			rp.Skipped++
+			continue
+		}
+
+		tq.runStat.Inc()
+		tso := tq.taskStat.GetStatByFuncName(t.GetTaskFnName())
+		t.Handle().Start()
+		err := t.RunWithClock(tq.runContext(), tso, 0, tq.clock)
+		if err != nil {
+			tq.logger.Error("%v", err)
+			rp.Errors = append(rp.Errors, err)
+		}
+		tq.taskEnded(t, tso, err)
+		rp.Run++
+		tq.handlePanic(err) // 정리한 뒤에 부른다.
+	}
+}
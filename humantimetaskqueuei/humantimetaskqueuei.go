@@ -16,7 +16,10 @@ import (
 	"context"
 	"time"
 
+	"github.com/kasworld/timedtask/deadletter"
 	"github.com/kasworld/timedtask/humantimetask"
+	"github.com/kasworld/timedtask/simulation"
+	"github.com/kasworld/timedtask/taskhandle"
 	"github.com/kasworld/timedtask/taskstat"
 )
 
@@ -26,8 +29,17 @@ type TaskQueueI interface {
 	GetTaskStat() *taskstat.TaskStat
 	UpdateTaskTime(t *humantimetask.Task, uptime time.Time) error
 	Remove(t *humantimetask.Task) error
+	GetByID(id humantimetask.TaskID) *humantimetask.Task
+	RemoveByID(id humantimetask.TaskID) error
+	RescheduleByID(id humantimetask.TaskID, uptime time.Time) error
+	ListDeadLetter() []deadletter.Entry[*humantimetask.Task]
+	GetDeadLetter(id deadletter.EntryID) (deadletter.Entry[*humantimetask.Task], bool)
+	RequeueDeadLetter(id deadletter.EntryID, uptime time.Time) error
+	DiscardDeadLetter(id deadletter.EntryID) bool
 	Push(t *humantimetask.Task)
+	PushWithHandle(t *humantimetask.Task) *taskhandle.Handle
 	Len() int
 	Run(ctx context.Context)
 	FlushTaskTill(till time.Time)
+	Simulate(end time.Time) (simulation.Report[time.Time], error)
 }
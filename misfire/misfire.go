@@ -0,0 +1,83 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 예정 시간 보다 popDelay 넘게 늦게 꺼낸 task (gametask, humantask) 의 처리 규칙
+// GC pause, 긴 Pause, 재시작 후 밀린 task 가 한번에 실행 되는 것을 막는다.
+package misfire
+
+import (
+	"fmt"
+)
+
+type Policy int
+
+const (
+	// task 면 queue 의 기본값, queue 면 RunNow
+	Default Policy = iota
+	// 늦었어도 지금 실행
+	RunNow
+	// 이번 실행은 버린다. 반복 task 는 지금 이후의 다음 시간으로 옮긴다.
+	Skip
+	// 지금 한번 실행하고, 반복 task 의 밀린 시간들은 건너 뛴다.
+	Coalesce
+	// 반복 task 는 실행 하지 않고 지금 이후의 다음 시간으로 옮긴다. 반복이 아니면 RunNow
+	NextSlot
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Default:
+		return "Default"
+	case RunNow:
+		return "RunNow"
+	case Skip:
+		return "Skip"
+	case Coalesce:
+		return "Coalesce"
+	case NextSlot:
+		return "NextSlot"
+	default:
+		return fmt.Sprintf("MisfirePolicy%d", int(p))
+	}
+}
+
+// task 의 Default 를 queue 의 policy 로 바꾼다.
+func (p Policy) Or(queuePolicy Policy) Policy {
+	if p != Default {
+		return p
+	}
+	if queuePolicy == Default {
+		return RunNow
+	}
+	return queuePolicy
+}
+
+// policy 를 적용한 결과 queue 가 할 일
+type Action int
+
+const (
+	ActionRun     Action = iota // 지금 실행
+	ActionRequeue               // 바뀐 시간으로 다시 넣는다.
+	ActionDrop                  // 실행 하지 않고 끝낸다.
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionRun:
+		return "Run"
+	case ActionRequeue:
+		return "Requeue"
+	case ActionDrop:
+		return "Drop"
+	default:
+		return fmt.Sprintf("MisfireAction%d", int(a))
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misfire
+
+import (
+	"testing"
+)
+
+func TestPolicy_Or(t *testing.T) {
+	for _, c := range []struct{ task, queue, want Policy }{
+		{Default, Default, RunNow},
+		{Default, Skip, Skip},
+		{Coalesce, Skip, Coalesce},
+	} {
+		if got := c.task.Or(c.queue); got != c.want {
+			t.Errorf("%v.Or(%v) %v, want %v", c.task, c.queue, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 함수 이름 이나 label 별 task (gametask, humantask) 동시 실행 수, 초당 시작 수 제한
+// 제한을 넘은 task 는 실행 하지 않고 미룬다.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type Limit struct {
+	MaxConcurrent int     // 0 : no limit
+	MaxPerSecond  float64 // 0 : no limit, 1초 보다 짧은 사이에는 1개 까지 몰릴수 있다.
+}
+
+func (lm Limit) String() string {
+	return fmt.Sprintf("Limit[%v %v/s]", lm.MaxConcurrent, lm.MaxPerSecond)
+}
+
+type keyState[T any] struct {
+	limit   Limit
+	running int // End 에서 자리를 넘겨 받은 task 포함
+	tokens  float64
+	lastAt  int64 // 마지막 TryStart 의 시간, TryStart 는 ns, TryStartTick 은 tick
+	hasLast bool
+	waiting []T // MaxConcurrent 때문에 기다리는 task, 먼저 온 순서
+}
+
+// T 는 task
+type Limiter[T comparable] struct {
+	mutex sync.Mutex
+	keys  map[string]*keyState[T]
+}
+
+func New[T comparable]() *Limiter[T] {
+	return &Limiter[T]{
+		keys: make(map[string]*keyState[T]),
+	}
+}
+
+// 제한이 없는 Limit 를 주면 제한을 없앤다.
+func (lr *Limiter[T]) SetLimit(key string, limit Limit) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	ks := lr.keys[key]
+	if ks == nil {
+		ks = &keyState[T]{}
+		lr.keys[key] = ks
+	}
+	ks.limit = limit
+	ks.tokens = burst(limit)
+}
+
+func (lr *Limiter[T]) GetLimit(key string) Limit {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	if ks := lr.keys[key]; ks != nil {
+		return ks.limit
+	}
+	return Limit{}
+}
+
+func burst(limit Limit) float64 {
+	return math.Max(1, limit.MaxPerSecond)
+}
+
+// t 를 지금 시작 할수 있으면 시작을 기록하고 true
+// MaxPerSecond 를 넘으면 false, 다시 해볼 때 까지의 시간
+// MaxConcurrent 를 넘으면 t 를 기다리게 하고 false, 0 : End 에서 자리를 넘겨 준다.
+func (lr *Limiter[T]) TryStart(key string, t T, now time.Time) (bool, time.Duration) {
+	ok, wait := lr.tryStart(key, t, now.UnixNano(), time.Nanosecond)
+	return ok, time.Duration(wait)
+}
+
+// TryStart 와 같지만 시간을 tick 으로 잰다. tickDuration 은 tick 1 의 실제 시간
+// 기다릴 시간도 tick 으로 돌려준다. 한 Limiter 에는 TryStart, TryStartTick 중 하나만 쓴다.
+func (lr *Limiter[T]) TryStartTick(key string, t T, now int64, tickDuration time.Duration) (bool, int64) {
+	return lr.tryStart(key, t, now, tickDuration)
+}
+
+// now, 돌려주는 wait 는 unit 단위
+func (lr *Limiter[T]) tryStart(key string, t T, now int64, unit time.Duration) (bool, int64) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	ks := lr.keys[key]
+	if ks == nil {
+		return true, 0
+	}
+	if rate := ks.limit.MaxPerSecond; rate > 0 {
+		if ks.hasLast {
+			elapsed := float64(now-ks.lastAt) * float64(unit) / float64(time.Second)
+			ks.tokens = math.Min(burst(ks.limit), ks.tokens+elapsed*rate)
+		}
+		ks.lastAt, ks.hasLast = now, true
+		if ks.tokens < 1 {
+			wait := (1 - ks.tokens) / rate * float64(time.Second) / float64(unit)
+			return false, int64(math.Ceil(wait))
+		}
+		ks.tokens-- // MaxConcurrent 로 기다리는 task 는 자리를 넘겨 받을때 다시 세지 않는다.
+	}
+	if ks.limit.MaxConcurrent > 0 && ks.running >= ks.limit.MaxConcurrent {
+		ks.waiting = append(ks.waiting, t)
+		return false, 0
+	}
+	ks.running++
+	return true, 0
+}
+
+// TryStart 로 시작한 task 가 끝났다.
+// 기다리던 task 가 있으면 실행 자리를 넘겨 주고 돌려준다.
+// 돌려받은 task 는 시작한 것으로 세므로 다시 TryStart 하지 않고 실행한다.
+func (lr *Limiter[T]) End(key string) (T, bool) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	var zero T
+	ks := lr.keys[key]
+	if ks == nil {
+		return zero, false
+	}
+	if len(ks.waiting) == 0 {
+		if ks.running > 0 {
+			ks.running--
+		}
+		return zero, false
+	}
+	t := ks.waiting[0]
+	ks.waiting = ks.waiting[1:]
+	return t, true
+}
+
+// 기다리던 task 면 지우고 true
+func (lr *Limiter[T]) RemoveWaiting(key string, t T) bool {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	ks := lr.keys[key]
+	if ks == nil {
+		return false
+	}
+	for i, w := range ks.waiting {
+		if w == t {
+			ks.waiting = append(ks.waiting[:i], ks.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// 실행중, 기다리는 수
+func (lr *Limiter[T]) Count(key string) (int, int) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	ks := lr.keys[key]
+	if ks == nil {
+		return 0, 0
+	}
+	return ks.running, len(ks.waiting)
+}
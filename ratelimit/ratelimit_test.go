@@ -0,0 +1,88 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_MaxConcurrent(t *testing.T) {
+	lr := New[int]()
+	lr.SetLimit("db", Limit{MaxConcurrent: 2})
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		ok, wait := lr.TryStart("db", i, now)
+		if ok != (i <= 2) || wait != 0 {
+			t.Errorf("TryStart %v %v %v", i, ok, wait)
+		}
+	}
+	if ok, _ := lr.TryStart("other", 5, now); !ok {
+		t.Errorf("no limit key blocked")
+	}
+	if !lr.RemoveWaiting("db", 4) {
+		t.Errorf("RemoveWaiting fail")
+	}
+	if next, ok := lr.End("db"); !ok || next != 3 {
+		t.Errorf("End %v %v", next, ok)
+	}
+	if running, waiting := lr.Count("db"); running != 2 || waiting != 0 {
+		t.Errorf("slot not handed to waiter, Count %v %v", running, waiting)
+	}
+	if ok, _ := lr.TryStart("db", 6, now); ok {
+		t.Errorf("handed slot taken by later task")
+	}
+	if next, ok := lr.End("db"); !ok || next != 6 {
+		t.Errorf("End %v %v", next, ok)
+	}
+	lr.End("db")
+	lr.End("db")
+	if running, waiting := lr.Count("db"); running != 0 || waiting != 0 {
+		t.Errorf("Count %v %v", running, waiting)
+	}
+}
+
+func TestLimiter_MaxPerSecond(t *testing.T) {
+	lr := New[int]()
+	lr.SetLimit("db", Limit{MaxPerSecond: 2})
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if ok, _ := lr.TryStart("db", i, now); !ok {
+			t.Fatalf("blocked in burst %v", i)
+		}
+		lr.End("db")
+	}
+	ok, wait := lr.TryStart("db", 2, now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("over rate %v %v", ok, wait)
+	}
+	if ok, _ := lr.TryStart("db", 2, now.Add(wait)); !ok {
+		t.Errorf("blocked after wait")
+	}
+}
+
+func TestLimiter_TryStartTick(t *testing.T) {
+	lr := New[int]()
+	lr.SetLimit("db", Limit{MaxPerSecond: 2})
+	tick := 50 * time.Millisecond
+	if ok, _ := lr.TryStartTick("db", 0, 100, tick); !ok {
+		t.Fatalf("blocked in burst")
+	}
+	lr.TryStartTick("db", 1, 100, tick)
+	ok, wait := lr.TryStartTick("db", 2, 100, tick)
+	if ok || wait != 10 {
+		t.Errorf("over rate %v %v, want 10 tick", ok, wait)
+	}
+	if ok, _ := lr.TryStartTick("db", 2, 100+wait, tick); !ok {
+		t.Errorf("blocked after wait")
+	}
+}
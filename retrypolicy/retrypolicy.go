@@ -0,0 +1,74 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 실패한 task (gametask, humantask) 를 다시 실행하는 규칙
+package retrypolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+type Policy struct {
+	MaxAttempts int           // 처음 실행 포함, 1 이하면 retry 안함
+	BaseDelay   time.Duration // 첫 retry 까지
+	MaxDelay    time.Duration // 0 : no limit
+	Multiplier  float64       // retry 마다 delay 를 곱한다, 1 보다 작으면 2
+	Jitter      float64       // 0~1, delay 를 ±Jitter 비율 안에서 흔든다.
+
+	Retryable func(err error) bool // nil : 모든 error 를 retry, context.Canceled 는 retry 안함
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("RetryPolicy[%v %v]", p.MaxAttempts, p.BaseDelay)
+}
+
+// attempt 번 실행해서 err 로 실패 했을때 다시 할지
+func (p *Policy) ShouldRetry(attempt int, err error) bool {
+	if err == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if errors.Is(err, context.Canceled) { // Remove, Run 끝으로 취소된 실행
+		return false
+	}
+	if p.Retryable != nil && !p.Retryable(err) {
+		return false
+	}
+	return true
+}
+
+// attempt 번 실패한 다음 retry 까지 기다릴 시간
+func (p *Policy) Delay(attempt int) time.Duration {
+	mul := p.Multiplier
+	if mul < 1 {
+		mul = 2
+	}
+	d := float64(p.BaseDelay)
+	for i := 1; i < attempt; i++ {
+		d *= mul
+		if p.MaxDelay > 0 && d >= float64(p.MaxDelay) {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	if d < 0 {
+		d = 0
+	}
+	return time.Duration(d)
+}
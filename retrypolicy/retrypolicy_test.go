@@ -0,0 +1,49 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrypolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPolicy(t *testing.T) {
+	errTemp := errors.New("temp")
+	p := &Policy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    3 * time.Second,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errTemp)
+		},
+	}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 3 * time.Second} {
+		if got := p.Delay(attempt); got != want {
+			t.Errorf("Delay(%v) %v, want %v", attempt, got, want)
+		}
+	}
+	if !p.ShouldRetry(2, errTemp) || p.ShouldRetry(3, errTemp) || p.ShouldRetry(1, errors.New("fatal")) {
+		t.Errorf("ShouldRetry fail")
+	}
+	if (&Policy{MaxAttempts: 3}).ShouldRetry(1, fmt.Errorf("task %w", context.Canceled)) {
+		t.Errorf("retry cancelled run")
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(1); d < time.Second/2 || d > time.Second*3/2 {
+			t.Fatalf("jitter out of range %v", d)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 같은 key 의 task (gametask, humantask) 가 겹쳐 실행 되지 않게 줄을 세운다.
+package serialkey
+
+import (
+	"sync"
+)
+
+type lane[T any] struct {
+	waiting []T // 먼저 온 순서
+}
+
+// T 는 task
+type Lanes[T comparable] struct {
+	mutex sync.Mutex
+	lanes map[string]*lane[T] // 실행중인 task 가 있는 key
+}
+
+func New[T comparable]() *Lanes[T] {
+	return &Lanes[T]{
+		lanes: make(map[string]*lane[T]),
+	}
+}
+
+// key 에 실행중인 task 가 없으면 t 를 실행중으로 하고 true
+// 있으면 t 를 줄 세우고 false, 앞의 task 가 끝나면 End 가 돌려준다.
+func (ls *Lanes[T]) TryStart(key string, t T) bool {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	ln := ls.lanes[key]
+	if ln == nil {
+		ls.lanes[key] = &lane[T]{}
+		return true
+	}
+	ln.waiting = append(ln.waiting, t)
+	return false
+}
+
+// key 의 실행중인 task 가 끝났다.
+// 기다리던 task 가 있으면 실행중으로 하고 돌려준다. 바로 실행 해야 한다.
+func (ls *Lanes[T]) End(key string) (T, bool) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	var zero T
+	ln := ls.lanes[key]
+	if ln == nil {
+		return zero, false
+	}
+	if len(ln.waiting) == 0 {
+		delete(ls.lanes, key)
+		return zero, false
+	}
+	t := ln.waiting[0]
+	ln.waiting = ln.waiting[1:]
+	return t, true
+}
+
+// 기다리던 task 면 지우고 true
+func (ls *Lanes[T]) RemoveWaiting(key string, t T) bool {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	ln := ls.lanes[key]
+	if ln == nil {
+		return false
+	}
+	for i, w := range ln.waiting {
+		if w == t {
+			ln.waiting = append(ln.waiting[:i], ln.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// key 에서 기다리는 수, 실행중인 task 가 없으면 -1
+func (ls *Lanes[T]) Waiting(key string) int {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	ln := ls.lanes[key]
+	if ln == nil {
+		return -1
+	}
+	return len(ln.waiting)
+}
+
+// 실행중인 task 가 있는 key 수
+func (ls *Lanes[T]) Len() int {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	return len(ls.lanes)
+}
@@ -0,0 +1,43 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serialkey
+
+import "testing"
+
+func TestLanes(t *testing.T) {
+	ls := New[int]()
+	if !ls.TryStart("p1", 1) || !ls.TryStart("p2", 10) {
+		t.Fatalf("first task not started")
+	}
+	for i := 2; i <= 4; i++ {
+		if ls.TryStart("p1", i) {
+			t.Errorf("started %v while running", i)
+		}
+	}
+	if ls.Waiting("p1") != 3 || ls.Len() != 2 {
+		t.Errorf("waiting %v, len %v", ls.Waiting("p1"), ls.Len())
+	}
+	if !ls.RemoveWaiting("p1", 3) {
+		t.Errorf("RemoveWaiting fail")
+	}
+	for _, want := range []int{2, 4} {
+		if next, ok := ls.End("p1"); !ok || next != want {
+			t.Errorf("End %v %v, want %v", next, ok, want)
+		}
+	}
+	if _, ok := ls.End("p1"); ok || ls.Waiting("p1") != -1 {
+		t.Errorf("lane not ended")
+	}
+	if !ls.TryStart("p1", 5) {
+		t.Errorf("not started after lane end")
+	}
+}
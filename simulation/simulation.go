@@ -0,0 +1,60 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// queue 를 가상 시간으로 끝까지 빠르게 돌려 보는 simulation 의 결과
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrRealClock = errors.New("simulation needs virtual clock")
+
+type StopReason int
+
+const (
+	StopEmpty   StopReason = iota // 실행할 task 가 없음
+	StopEndTime                   // 다음 task 가 end 뒤
+	StopPaused                    // queue 가 paused
+)
+
+func (sr StopReason) String() string {
+	switch sr {
+	case StopEmpty:
+		return "Empty"
+	case StopEndTime:
+		return "EndTime"
+	case StopPaused:
+		return "Paused"
+	default:
+		return fmt.Sprintf("StopReason(%d)", int(sr))
+	}
+}
+
+// T 는 time.Time 이나 gametick.GameTick
+// 함수 별 실행 수, 시간은 queue 의 taskstat 에 있다.
+type Report[T any] struct {
+	From    T // 가상 시간, 시작
+	To      T // 가상 시간, 끝
+	Stopped StopReason
+	Steps   int           // 가상 시간을 옮긴 횟수
+	Run     int           // 실행한 task 수
+	Skipped int           // expiry 등으로 실행 하지 않은 task 수
+	Errors  []error       // 실패한 task 의 error, 실행 순서
+	Elapsed time.Duration // 실제 걸린 시간
+}
+
+func (rp Report[T]) String() string {
+	return fmt.Sprintf("Report[%v ~ %v %v steps %v run %v skip %v err %v %v]",
+		rp.From, rp.To, rp.Stopped, rp.Steps, rp.Run, rp.Skipped, len(rp.Errors), rp.Elapsed)
+}
@@ -0,0 +1,24 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReport_String(t *testing.T) {
+	rp := Report[int]{From: 1, To: 10, Stopped: StopEndTime, Run: 3}
+	if s := rp.String(); !strings.Contains(s, "1 ~ 10 EndTime") || !strings.Contains(s, "run 3") {
+		t.Errorf("%v", s)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// queue 안의 task (gametask, humantask) 사이의 선행 관계
+// task 는 시간이 되고 선행 task 가 모두 성공 해야 실행 된다.
+package taskdep
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrPrerequisiteFailed = errors.New("prerequisite task failed")
+
+// 선행 task 가 실패 하거나 취소 되었을때 후행 task 처리
+type FailPolicy int
+
+const (
+	// 후행 task 를 취소 한다. 그 후행 task 들도 실패로 본다.
+	FailCancel FailPolicy = iota
+	// 후행 task 를 실행 하지 않고 넘어간다. 그 후행 task 들은 성공으로 본다.
+	FailSkip
+)
+
+func (fp FailPolicy) String() string {
+	switch fp {
+	case FailCancel:
+		return "FailCancel"
+	case FailSkip:
+		return "FailSkip"
+	default:
+		return fmt.Sprintf("FailPolicy%d", int(fp))
+	}
+}
+
+type node[K comparable] struct {
+	pending map[K]bool // 아직 성공 하지 않은 선행 task
+	onFail  FailPolicy
+	waiting bool // 시간이 되었지만 선행 task 를 기다림
+}
+
+// lock 을 하지 않는다. queue 의 mutex 로 보호 한다.
+type Graph[K comparable] struct {
+	nodes      map[K]*node[K] // 선행 task 가 있는 task
+	dependents map[K][]K      // 선행 task -> 후행 task 들
+}
+
+func New[K comparable]() *Graph[K] {
+	return &Graph[K]{
+		nodes:      make(map[K]*node[K]),
+		dependents: make(map[K][]K),
+	}
+}
+
+func (g *Graph[K]) String() string {
+	return fmt.Sprintf("TaskDepGraph[%v]", len(g.nodes))
+}
+
+// dependent 는 prereqs 가 모두 성공 해야 실행 된다.
+func (g *Graph[K]) Add(dependent K, onFail FailPolicy, prereqs ...K) {
+	nd := g.nodes[dependent]
+	if nd == nil {
+		nd = &node[K]{pending: make(map[K]bool)}
+		g.nodes[dependent] = nd
+	}
+	nd.onFail = onFail
+	for _, p := range prereqs {
+		if nd.pending[p] {
+			continue
+		}
+		nd.pending[p] = true
+		g.dependents[p] = append(g.dependents[p], dependent)
+	}
+}
+
+// 기다리는 선행 task 가 없는지
+func (g *Graph[K]) IsReady(k K) bool {
+	nd := g.nodes[k]
+	return nd == nil || len(nd.pending) == 0
+}
+
+// 시간이 되었을때 부른다. 선행 task 를 기다려야 하면 기억하고 true
+func (g *Graph[K]) Hold(k K) bool {
+	if g.IsReady(k) {
+		return false
+	}
+	g.nodes[k].waiting = true
+	return true
+}
+
+// 기다리고 있던 task 면 더 기다리지 않고 true
+func (g *Graph[K]) Unhold(k K) bool {
+	nd := g.nodes[k]
+	if nd == nil || !nd.waiting {
+		return false
+	}
+	nd.waiting = false
+	return true
+}
+
+// 선행 task 의 종료 처리 결과
+type Result[K comparable] struct {
+	Ready     []K // 기다리던 선행 task 가 모두 성공, 다시 queue 에 넣는다.
+	Cancelled []K // FailCancel 로 취소, 실행 하지 않는다.
+	Skipped   []K // FailSkip 으로 넘어감, 실행 하지 않는다.
+}
+
+// k 가 성공 했다.
+func (g *Graph[K]) Succeeded(k K) Result[K] {
+	var rtn Result[K]
+	g.succeeded(k, &rtn)
+	return rtn
+}
+
+// k 가 실패 하거나 취소 되었다.
+func (g *Graph[K]) Failed(k K) Result[K] {
+	var rtn Result[K]
+	g.failed(k, &rtn)
+	return rtn
+}
+
+func (g *Graph[K]) succeeded(k K, rtn *Result[K]) {
+	delete(g.nodes, k)
+	deps := g.dependents[k]
+	delete(g.dependents, k)
+	for _, d := range deps {
+		nd := g.nodes[d]
+		if nd == nil {
+			continue
+		}
+		delete(nd.pending, k)
+		if len(nd.pending) == 0 && nd.waiting {
+			nd.waiting = false
+			rtn.Ready = append(rtn.Ready, d)
+		}
+	}
+}
+
+func (g *Graph[K]) failed(k K, rtn *Result[K]) {
+	delete(g.nodes, k)
+	deps := g.dependents[k]
+	delete(g.dependents, k)
+	for _, d := range deps {
+		nd := g.nodes[d]
+		if nd == nil { // 이미 처리 됨
+			continue
+		}
+		switch nd.onFail {
+		case FailSkip:
+			rtn.Skipped = append(rtn.Skipped, d)
+			g.succeeded(d, rtn)
+		default:
+			rtn.Cancelled = append(rtn.Cancelled, d)
+			g.failed(d, rtn)
+		}
+	}
+}
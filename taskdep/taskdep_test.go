@@ -0,0 +1,47 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskdep
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	// 1 -> 2 -> 3, 1 -> 4(skip) -> 5
+	g := New[int]()
+	g.Add(2, FailCancel, 1)
+	g.Add(3, FailCancel, 2)
+	g.Add(4, FailSkip, 1)
+	g.Add(5, FailCancel, 4)
+	if !g.IsReady(1) || g.IsReady(2) {
+		t.Errorf("IsReady fail")
+	}
+	if !g.Hold(5) || g.Hold(1) {
+		t.Errorf("Hold fail")
+	}
+	r := g.Failed(1)
+	want := Result[int]{Ready: []int{5}, Cancelled: []int{2, 3}, Skipped: []int{4}}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("Failed %+v, want %+v", r, want)
+	}
+
+	g = New[int]()
+	g.Add(3, FailCancel, 1, 2)
+	g.Hold(3)
+	if r := g.Succeeded(1); len(r.Ready) != 0 {
+		t.Errorf("ready before all prereqs %+v", r)
+	}
+	if r := g.Succeeded(2); !reflect.DeepEqual(r.Ready, []int{3}) {
+		t.Errorf("Succeeded %+v", r)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// queue 에 넣은 task (gametask, humantask) 가 끝나기를 기다리고 결과를 받는다.
+package taskhandle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrCancelled = errors.New("task cancelled")
+
+type Status int32
+
+const (
+	Pending Status = iota
+	Running
+	Succeeded
+	Failed
+	Cancelled
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pending:
+		return "Pending"
+	case Running:
+		return "Running"
+	case Succeeded:
+		return "Succeeded"
+	case Failed:
+		return "Failed"
+	case Cancelled:
+		return "Cancelled"
+	default:
+		return fmt.Sprintf("Status%d", int32(s))
+	}
+}
+
+// 끝났는지
+func (s Status) IsDone() bool {
+	return s >= Succeeded
+}
+
+// 상태를 바꾸는 Start, Requeue, Finish, Cancel 은 queue 에서 부른다.
+// nil Handle 의 상태를 바꾸는 method 는 아무것도 안한다. Status, Err, Done, Wait 는 nil 에 쓸수 없다.
+type Handle struct {
+	mutex  sync.Mutex
+	status Status
+	err    error
+	done   chan struct{}
+}
+
+func New() *Handle {
+	return &Handle{
+		done: make(chan struct{}),
+	}
+}
+
+func (h *Handle) String() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return fmt.Sprintf("TaskHandle[%v %v]", h.status, h.err)
+}
+
+func (h *Handle) Status() Status {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.status
+}
+
+// 끝나면 닫힌다.
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// 끝나기 전과 성공 하면 nil, 취소 되었으면 ErrCancelled
+func (h *Handle) Err() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.err
+}
+
+// 끝나기를 기다려 Err 를 돌려준다. ctx 가 먼저 끝나면 ctx.Err()
+func (h *Handle) Wait(ctx context.Context) error {
+	select {
+	case <-h.done:
+		return h.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 실행을 시작함
+func (h *Handle) Start() {
+	h.setStatus(Running)
+}
+
+// retry, 반복 으로 다시 queue 에 들어감
+func (h *Handle) Requeue() {
+	h.setStatus(Pending)
+}
+
+// err 가 nil 이면 Succeeded, ctx 취소로 끝났으면 Cancelled, 아니면 Failed
+func (h *Handle) Finish(err error) {
+	switch {
+	case err == nil:
+		h.finish(Succeeded, nil)
+	case errors.Is(err, context.Canceled):
+		h.finish(Cancelled, err)
+	default:
+		h.finish(Failed, err)
+	}
+}
+
+// 실행 하지 않고 queue 에서 지워짐
+func (h *Handle) Cancel() {
+	h.finish(Cancelled, ErrCancelled)
+}
+
+// 실행 하지 않고 queue 에서 지워짐, Err 는 err
+func (h *Handle) CancelWithErr(err error) {
+	h.finish(Cancelled, err)
+}
+
+func (h *Handle) setStatus(status Status) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.status.IsDone() {
+		return
+	}
+	h.status = status
+}
+
+func (h *Handle) finish(status Status, err error) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.status.IsDone() {
+		return
+	}
+	h.status = status
+	h.err = err
+	close(h.done)
+}
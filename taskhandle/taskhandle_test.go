@@ -0,0 +1,50 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskhandle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHandle(t *testing.T) {
+	h := New()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := h.Wait(ctx); err != context.DeadlineExceeded || h.Status() != Pending {
+		t.Errorf("Wait before done %v %v", err, h.Status())
+	}
+	h.Start()
+	errFail := errors.New("fail")
+	h.Finish(errFail)
+	h.Finish(nil) // ignored
+	h.Cancel()    // ignored
+	if err := h.Wait(context.Background()); err != errFail || h.Status() != Failed {
+		t.Errorf("Wait %v %v", err, h.Status())
+	}
+
+	h = New()
+	h.Finish(fmt.Errorf("run %w", context.Canceled))
+	if h.Status() != Cancelled {
+		t.Errorf("ctx cancel %v", h.Status())
+	}
+
+	var nilh *Handle
+	nilh.Start()
+	nilh.Requeue()
+	nilh.Finish(nil)
+	nilh.Cancel()
+	nilh.CancelWithErr(errFail)
+}
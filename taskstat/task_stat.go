@@ -14,27 +14,78 @@ package taskstat
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	statObjRunning int32 = iota
+	statObjCommitted
+	statObjTimeout
+	statObjPanic
+)
+
 type StatObj struct {
 	startTime time.Time
 	statRef   *Stat
+	state     int32 // Commit, Timeout, Panic 중 먼저 한것만 기록
 }
 
 func (so *StatObj) Commit() {
+	if !atomic.CompareAndSwapInt32(&so.state, statObjRunning, statObjCommitted) {
+		return
+	}
 	so.statRef.commit(so.startTime)
 }
 
 func (so *StatObj) Success() {
+	if atomic.LoadInt32(&so.state) != statObjCommitted {
+		return
+	}
 	so.statRef.success()
 }
 
+// 실행중 panic 으로 끝남, 이미 Commit 되었으면 false
+// Timeout 뒤에 panic 하면 끝난 것은 Timeout 때 세었으므로 panic 만 센다.
+func (so *StatObj) Panic() bool {
+	if atomic.CompareAndSwapInt32(&so.state, statObjTimeout, statObjPanic) {
+		so.statRef.panic()
+		return true
+	}
+	if !atomic.CompareAndSwapInt32(&so.state, statObjRunning, statObjPanic) {
+		return false
+	}
+	so.statRef.commit(so.startTime)
+	so.statRef.panic()
+	return true
+}
+
+// 실패해서 다시 실행하기로 함
+func (so *StatObj) Retry() {
+	so.statRef.retry()
+}
+
+// 실행이 끝나기 전에 timeout, 이미 Commit 되었으면 false
+func (so *StatObj) Timeout() bool {
+	if !atomic.CompareAndSwapInt32(&so.state, statObjRunning, statObjTimeout) {
+		return false
+	}
+	so.statRef.commit(so.startTime)
+	so.statRef.timeout()
+	return true
+}
+
 type Stat struct {
 	mutex          sync.Mutex
 	totalDur       time.Duration
 	StartCount     int64
 	SuccessCount   int64
+	TimeoutCount   int64
+	RetryCount     int64
+	PanicCount     int64
+	MisfireCount   int64
+	ExpireCount    int64
+	DeferCount     int64
 	EndCount       int64
 	HighMS         float64
 	LowMS          float64
@@ -78,6 +129,36 @@ func (st *Stat) success() {
 	st.SuccessCount++
 	st.mutex.Unlock()
 }
+func (st *Stat) timeout() {
+	st.mutex.Lock()
+	st.TimeoutCount++
+	st.mutex.Unlock()
+}
+func (st *Stat) panic() {
+	st.mutex.Lock()
+	st.PanicCount++
+	st.mutex.Unlock()
+}
+func (st *Stat) misfire() {
+	st.mutex.Lock()
+	st.MisfireCount++
+	st.mutex.Unlock()
+}
+func (st *Stat) expire() {
+	st.mutex.Lock()
+	st.ExpireCount++
+	st.mutex.Unlock()
+}
+func (st *Stat) deferred() {
+	st.mutex.Lock()
+	st.DeferCount++
+	st.mutex.Unlock()
+}
+func (st *Stat) retry() {
+	st.mutex.Lock()
+	st.RetryCount++
+	st.mutex.Unlock()
+}
 func (st Stat) FailCount() int64 {
 	return st.EndCount - st.SuccessCount
 }
@@ -106,19 +187,41 @@ func New() *TaskStat {
 }
 
 func (fm *TaskStat) GetStatByFuncName(fnname string) *StatObj {
+	return fm.getOrNew(fnname).Open()
+}
+
+// 예정 시간 보다 늦게 꺼냄, 실행 여부와 무관
+func (fm *TaskStat) Misfire(fnname string) {
+	fm.getOrNew(fnname).misfire()
+}
+
+// expiry 가 지나서 실행 하지 않고 버림
+func (fm *TaskStat) Expire(fnname string) {
+	fm.getOrNew(fnname).expire()
+}
+
+// rate limit 을 넘어서 실행 하지 않고 미룸
+func (fm *TaskStat) Defer(fnname string) {
+	fm.getOrNew(fnname).deferred()
+}
+
+func (fm *TaskStat) getOrNew(fnname string) *Stat {
 	fm.mutex.Lock()
 	defer fm.mutex.Unlock()
 	taskstat, ok := fm.taskMap[fnname]
-	if ok {
-		stobj := taskstat.Open()
-		return stobj
-	} else {
+	if !ok {
 		taskstat = &Stat{
 			LowMS:          100000.0,
 			lastUpdateTime: time.Now().UTC(),
 		}
-		stobj := taskstat.Open()
 		fm.taskMap[fnname] = taskstat
-		return stobj
 	}
+	return taskstat
+}
+
+// 통계를 읽기만 한다. 없으면 nil
+func (fm *TaskStat) GetStat(fnname string) *Stat {
+	fm.mutex.RLock()
+	defer fm.mutex.RUnlock()
+	return fm.taskMap[fnname]
 }
@@ -24,8 +24,8 @@ func (fm TaskStat) String() string {
 	for k, v := range fm.taskMap {
 		fmt.Fprintf(
 			&buf,
-			" Avg ms : |%13.6f| StartCount : |%15v| EndCount : |%15v| SuccessCount : |%15v| failCount : |%15v| High ms(10s) : |%13.6f| Low ms(10s) : |%13.6f| funcName : %s\n",
-			v.Avg(), v.StartCount, v.EndCount, v.SuccessCount, v.StartCount-v.SuccessCount, v.HighMS, v.LowMS, k)
+			" Avg ms : |%13.6f| StartCount : |%15v| EndCount : |%15v| SuccessCount : |%15v| failCount : |%15v| TimeoutCount : |%15v| RetryCount : |%15v| PanicCount : |%15v| MisfireCount : |%15v| ExpireCount : |%15v| DeferCount : |%15v| High ms(10s) : |%13.6f| Low ms(10s) : |%13.6f| funcName : %s\n",
+			v.Avg(), v.StartCount, v.EndCount, v.SuccessCount, v.StartCount-v.SuccessCount, v.TimeoutCount, v.RetryCount, v.PanicCount, v.MisfireCount, v.ExpireCount, v.DeferCount, v.HighMS, v.LowMS, k)
 	}
 	fmt.Fprintf(&buf, "\n")
 	return buf.String()
@@ -39,6 +39,12 @@ const (
 <th>RunCount</th>
 <th>SuccessCount</th>
 <th>failCount</th>
+<th>TimeoutCount</th>
+<th>RetryCount</th>
+<th>PanicCount</th>
+<th>MisfireCount</th>
+<th>ExpireCount</th>
+<th>DeferCount</th>
 <th>High ms(last 10s)</th>
 <th>Low ms(last 10s)</th>
 <th>funcName</th>
@@ -50,6 +56,12 @@ const (
 <td>{{$v.RunCount}}</td>
 <td>{{$v.SuccessCount}}</td>
 <td>{{$v.FailCount }}</td>
+<td>{{$v.TimeoutCount}}</td>
+<td>{{$v.RetryCount}}</td>
+<td>{{$v.PanicCount}}</td>
+<td>{{$v.MisfireCount}}</td>
+<td>{{$v.ExpireCount}}</td>
+<td>{{$v.DeferCount}}</td>
 <td>{{printf "%13.6f" $v.HighMS }}</td>
 <td>{{printf "%13.6f" $v.LowMS}}</td>
 <td>{{$i}}</td>
@@ -0,0 +1,128 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// gameticktaskqueue 가 쓰는 game tick, globalgametick 과 test 에서 옮기는 tick
+package ticksource
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kasworld/gametick"
+	"github.com/kasworld/globalgametick"
+)
+
+type TickSource interface {
+	GetGameTick() gametick.GameTick
+	// gametick 1 의 실제 시간, timer 를 맞출 때 쓴다.
+	TickDuration() time.Duration
+	// tick 이 바뀌면 받는 chan, nil 이면 알리지 않는다. timer 로 기다린다.
+	Subscribe() <-chan gametick.GameTick
+	Unsubscribe(ch <-chan gametick.GameTick)
+}
+
+func ToDuration(ts TickSource, tick gametick.GameTick) time.Duration {
+	return time.Duration(tick) * ts.TickDuration()
+}
+
+func FromDuration(ts TickSource, d time.Duration) gametick.GameTick {
+	td := ts.TickDuration()
+	if td <= 0 {
+		return gametick.FromTimeDurationToTickType(d)
+	}
+	return gametick.GameTick(d / td)
+}
+
+// process 의 globalgametick, 기본값
+type Global struct{}
+
+func (Global) GetGameTick() gametick.GameTick {
+	return globalgametick.GetGameTick()
+}
+
+func (Global) TickDuration() time.Duration {
+	return gametick.GameTick(1).ToTimeDuration()
+}
+
+func (Global) Subscribe() <-chan gametick.GameTick {
+	return nil
+}
+
+func (Global) Unsubscribe(ch <-chan gametick.GameTick) {
+}
+
+// Set, Advance 를 불러야 tick 이 간다. 바뀔 때 마다 Subscribe 한 chan 에 알린다.
+// 받지 않은 알림이 있으면 마지막 tick 만 남긴다.
+type Manual struct {
+	mutex        sync.Mutex
+	tick         gametick.GameTick
+	tickDuration time.Duration
+	subs         []chan gametick.GameTick
+}
+
+// tickDuration 이 0 이면 Global 과 같다.
+func NewManual(tick gametick.GameTick, tickDuration time.Duration) *Manual {
+	if tickDuration <= 0 {
+		tickDuration = Global{}.TickDuration()
+	}
+	return &Manual{
+		tick:         tick,
+		tickDuration: tickDuration,
+	}
+}
+
+func (m *Manual) GetGameTick() gametick.GameTick {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.tick
+}
+
+func (m *Manual) TickDuration() time.Duration {
+	return m.tickDuration
+}
+
+func (m *Manual) Subscribe() <-chan gametick.GameTick {
+	ch := make(chan gametick.GameTick, 1)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.subs = append(m.subs, ch)
+	return ch
+}
+
+func (m *Manual) Unsubscribe(ch <-chan gametick.GameTick) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i, v := range m.subs {
+		if v == ch {
+			m.subs = append(m.subs[:i], m.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *Manual) Set(tick gametick.GameTick) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tick = tick
+	for _, ch := range m.subs {
+		select {
+		case <-ch: // 이전 알림을 버린다.
+		default:
+		}
+		ch <- tick
+	}
+}
+
+func (m *Manual) Advance(n gametick.GameTick) gametick.GameTick {
+	tick := m.GetGameTick() + n
+	m.Set(tick)
+	return tick
+}
@@ -0,0 +1,40 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ticksource
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManual(t *testing.T) {
+	m := NewManual(100, 50*time.Millisecond)
+	ch := m.Subscribe()
+	m.Advance(1)
+	m.Advance(2)
+	if got := <-ch; got != 103 || m.GetGameTick() != 103 {
+		t.Errorf("notified %v, tick %v", got, m.GetGameTick())
+	}
+	if ToDuration(m, 4) != 200*time.Millisecond || FromDuration(m, time.Second) != 20 {
+		t.Errorf("conversion %v %v", ToDuration(m, 4), FromDuration(m, time.Second))
+	}
+	m.Unsubscribe(ch)
+	m.Set(200)
+	select {
+	case got := <-ch:
+		t.Errorf("notified after unsubscribe %v", got)
+	default:
+	}
+	if (Global{}).Subscribe() != nil {
+		t.Errorf("global notifies")
+	}
+}
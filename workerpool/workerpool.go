@@ -0,0 +1,197 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// task (gametask, humantask) 를 정해진 수의 goroutine 으로 실행하는 pool
+package workerpool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// backlog 가 찼을때 하는 일
+type Saturation int
+
+const (
+	// backlog 에 자리가 날때 까지 기다린다. Submit 한 쪽이 멈춘다.
+	Block Saturation = iota
+	// Submit 한 goroutine 에서 바로 실행한다.
+	CallerRuns
+	// 실행 하지 않고 Submit 이 false
+	Drop
+)
+
+func (s Saturation) String() string {
+	switch s {
+	case Block:
+		return "Block"
+	case CallerRuns:
+		return "CallerRuns"
+	case Drop:
+		return "Drop"
+	default:
+		return fmt.Sprintf("Saturation(%d)", int(s))
+	}
+}
+
+var ErrSaturated = errors.New("worker pool saturated")
+
+type Config struct {
+	Size       int // worker goroutine 수, 0 : pool 을 쓰지 않음
+	Backlog    int // 실행을 기다리는 최대 수
+	Saturation Saturation
+}
+
+func (c Config) String() string {
+	return fmt.Sprintf("Config[%v backlog %v %v]", c.Size, c.Backlog, c.Saturation)
+}
+
+type Pool struct {
+	config    Config
+	mutex     sync.RWMutex // closed 와 jobs 에 보내기
+	closed    bool
+	jobs      chan func()
+	workersWG sync.WaitGroup
+
+	busy       int64
+	submitted  int64
+	callerRuns int64
+	dropped    int64
+}
+
+// Size 만큼 worker 를 띄운다. Size 가 1 보다 작으면 1
+func New(config Config) *Pool {
+	if config.Size < 1 {
+		config.Size = 1
+	}
+	if config.Backlog < 0 {
+		config.Backlog = 0
+	}
+	p := &Pool{
+		config: config,
+		jobs:   make(chan func(), config.Backlog),
+	}
+	p.workersWG.Add(config.Size)
+	for i := 0; i < config.Size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.workersWG.Done()
+	for fn := range p.jobs {
+		atomic.AddInt64(&p.busy, 1)
+		fn()
+		atomic.AddInt64(&p.busy, -1)
+	}
+}
+
+func (p *Pool) Config() Config {
+	return p.config
+}
+
+// fn 을 실행 하도록 넣는다. 실행 하지 않을 fn 이면 false
+// Close 한 pool 이면 CallerRuns 처럼 바로 실행한다.
+func (p *Pool) Submit(fn func()) bool {
+	p.mutex.RLock()
+	if p.closed {
+		p.mutex.RUnlock()
+		atomic.AddInt64(&p.callerRuns, 1)
+		fn()
+		return true
+	}
+	select {
+	case p.jobs <- fn:
+		p.mutex.RUnlock()
+		atomic.AddInt64(&p.submitted, 1)
+		return true
+	default:
+	}
+	switch p.config.Saturation {
+	case CallerRuns:
+		p.mutex.RUnlock()
+		atomic.AddInt64(&p.callerRuns, 1)
+		fn()
+		return true
+	case Drop:
+		p.mutex.RUnlock()
+		atomic.AddInt64(&p.dropped, 1)
+		return false
+	default:
+		p.jobs <- fn
+		p.mutex.RUnlock()
+		atomic.AddInt64(&p.submitted, 1)
+		return true
+	}
+}
+
+// 더 받지 않고 backlog 에 남은 것을 모두 실행한 뒤 worker 를 끝낸다.
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.jobs)
+	p.mutex.Unlock()
+	p.workersWG.Wait()
+}
+
+type Stat struct {
+	Size       int
+	Busy       int // 실행중인 worker 수
+	Depth      int // backlog 에서 기다리는 수
+	Backlog    int
+	Submitted  int64
+	CallerRuns int64
+	Dropped    int64
+}
+
+// 실행중인 worker 의 비율 0 ~ 1
+func (st Stat) Utilization() float64 {
+	if st.Size == 0 {
+		return 0
+	}
+	return float64(st.Busy) / float64(st.Size)
+}
+
+func (st Stat) String() string {
+	return fmt.Sprintf("Pool[busy %v/%v %.0f%% depth %v/%v dropped %v]",
+		st.Busy, st.Size, st.Utilization()*100, st.Depth, st.Backlog, st.Dropped)
+}
+
+func (p *Pool) Stat() Stat {
+	return Stat{
+		Size:       p.config.Size,
+		Busy:       int(atomic.LoadInt64(&p.busy)),
+		Depth:      len(p.jobs),
+		Backlog:    p.config.Backlog,
+		Submitted:  atomic.LoadInt64(&p.submitted),
+		CallerRuns: atomic.LoadInt64(&p.callerRuns),
+		Dropped:    atomic.LoadInt64(&p.dropped),
+	}
+}
+
+func (p *Pool) String() string {
+	return p.Stat().String()
+}
+
+// executor.Executor, Drop 으로 실행 하지 않으면 ErrSaturated
+func (p *Pool) Execute(run func()) error {
+	if !p.Submit(run) {
+		return ErrSaturated
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2015,2016,2017,2018,2019 SeukWon Kang (kasworld@gmail.com)
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workerpool
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPool_Saturation(t *testing.T) {
+	for _, sat := range []Saturation{Block, CallerRuns, Drop} {
+		p := New(Config{Size: 1, Backlog: 1, Saturation: sat})
+		block := make(chan struct{})
+		started := make(chan struct{})
+		p.Submit(func() { close(started); <-block })
+		<-started
+		var wg sync.WaitGroup
+		ran := 0
+		var mutex sync.Mutex
+		count := func() { mutex.Lock(); ran++; mutex.Unlock() }
+		if !p.Submit(count) {
+			t.Errorf("%v backlog not accepted", sat)
+		}
+		if st := p.Stat(); st.Busy != 1 || st.Depth != 1 || st.Utilization() != 1 {
+			t.Errorf("%v stat %v", sat, st)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok := p.Submit(count); ok == (sat == Drop) {
+				t.Errorf("%v saturated submit %v", sat, ok)
+			}
+		}()
+		if sat != Block {
+			wg.Wait()
+		}
+		close(block)
+		wg.Wait()
+		p.Close()
+		want := 2
+		if sat == Drop {
+			want = 1
+		}
+		if ran != want {
+			t.Errorf("%v ran %v, want %v", sat, ran, want)
+		}
+	}
+}